@@ -0,0 +1,15 @@
+// Package api embeds the OpenAPI description of the HTTP API and a page that
+// renders it, so the server can serve both without files on disk.
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI 3.1 document describing every route.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Docs is a self-contained HTML page that renders Spec.
+//
+//go:embed docs.html
+var Docs []byte
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/config"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/media"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"time"
+)
+
+type attachment struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+func attachmentFromRow(a database.ChirpAttachment) attachment {
+	return attachment{
+		ID:           a.ID,
+		ContentType:  a.ContentType,
+		SizeBytes:    a.SizeBytes,
+		Width:        a.Width,
+		Height:       a.Height,
+		URL:          "/api/media/" + a.ID.String(),
+		ThumbnailURL: "/api/media/" + a.ID.String() + "/thumbnail",
+	}
+}
+
+func newBlobStore(conf *config.Config) (media.BlobStore, error) {
+	if conf.MediaBackend == "s3" {
+		return media.NewS3Store(
+			conf.S3Endpoint,
+			conf.S3Bucket,
+			conf.S3Region,
+			conf.S3AccessKey,
+			conf.S3SecretKey,
+		), nil
+	}
+	return media.NewLocalStore(conf.MediaDir)
+}
+
+// chirpResponses converts database chirps into API chirps with their
+// attachments, fetching the attachments for every chirp in one query.
+func (cfg *apiConfig) chirpResponses(ctx context.Context, chirps []database.Chirp) ([]chirp, error) {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, c := range chirps {
+		ids = append(ids, c.ID)
+	}
+	byChirp := map[uuid.UUID][]attachment{}
+	if len(ids) > 0 {
+		rows, err := cfg.db.GetAttachmentsForChirps(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range rows {
+			byChirp[a.ChirpID] = append(byChirp[a.ChirpID], attachmentFromRow(a))
+		}
+	}
+	theChirps := []chirp{}
+	for _, c := range chirps {
+		m := byChirp[c.ID]
+		if m == nil {
+			m = []attachment{}
+		}
+		theChirps = append(theChirps, chirp{
+			ID:        c.ID,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			Body:      c.Body,
+			UserID:    c.UserID,
+			Edited:    c.EditedAt.Valid,
+			Media:     m,
+		})
+	}
+	return theChirps, nil
+}
+
+func (cfg *apiConfig) handlerUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	c, err := cfg.db.GetChirpByID(r.Context(), database.GetChirpByIDParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	if c.UserID != userID {
+		respondWithError(w, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
+		return
+	}
+	_, limits, err := cfg.limitsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	count, err := cfg.db.CountChirpAttachments(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue reading attachments", err)
+		return
+	}
+	if count >= int64(limits.MediaPerChirp) {
+		respondWithError(w, http.StatusBadRequest, "chirp already has the maximum number of attachments", nil)
+		return
+	}
+
+	// Leave some room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.maxUploadBytes+(1<<20))
+	if err := r.ParseMultipartForm(cfg.maxUploadBytes); err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "upload is too large", err)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "missing file", err)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, cfg.maxUploadBytes+1))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error reading upload", err)
+		return
+	}
+	processed, err := media.Process(data, cfg.maxUploadBytes)
+	if errors.Is(err, media.ErrTooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "upload is too large", err)
+		return
+	}
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "only jpeg, png, gif and webp images are supported", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error processing upload", err)
+		return
+	}
+
+	id := uuid.New()
+	key := "chirps/" + chirpID.String() + "/" + id.String() + processed.Extension
+	thumbKey := "chirps/" + chirpID.String() + "/" + id.String() + "_thumb" + processed.ThumbnailExtension
+	if err := cfg.media.Put(r.Context(), key, bytes.NewReader(data), processed.ContentType); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error storing upload", err)
+		return
+	}
+	if err := cfg.media.Put(r.Context(), thumbKey, bytes.NewReader(processed.Thumbnail), processed.ThumbnailContentType); err != nil {
+		cfg.media.Delete(r.Context(), key)
+		respondWithError(w, http.StatusInternalServerError, "error storing upload", err)
+		return
+	}
+	a, err := cfg.db.CreateChirpAttachment(r.Context(), database.CreateChirpAttachmentParams{
+		ID:                   id,
+		CreatedAt:            time.Now(),
+		ChirpID:              chirpID,
+		UserID:               userID,
+		ContentType:          processed.ContentType,
+		SizeBytes:            int64(len(data)),
+		Width:                int32(processed.Width),
+		Height:               int32(processed.Height),
+		StorageKey:           key,
+		ThumbnailKey:         thumbKey,
+		ThumbnailContentType: processed.ThumbnailContentType,
+	})
+	if err != nil {
+		cfg.media.Delete(r.Context(), key)
+		cfg.media.Delete(r.Context(), thumbKey)
+		respondWithError(w, http.StatusInternalServerError, "issue inserting in to database", err)
+		return
+	}
+	respondWithJson(w, http.StatusCreated, attachmentFromRow(a))
+}
+
+func (cfg *apiConfig) handlerGetAttachment(w http.ResponseWriter, r *http.Request) {
+	cfg.serveAttachment(w, r, false)
+}
+
+func (cfg *apiConfig) handlerGetAttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveAttachment(w, r, true)
+}
+
+func (cfg *apiConfig) serveAttachment(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	id, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	a, err := cfg.db.GetChirpAttachmentByID(r.Context(), database.GetChirpAttachmentByIDParams{
+		ID:       id,
+		ViewerID: cfg.viewerID(r),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	key, contentType := a.StorageKey, a.ContentType
+	if thumbnail {
+		key, contentType = a.ThumbnailKey, a.ThumbnailContentType
+	}
+	blob, err := cfg.media.Get(r.Context(), key)
+	if errors.Is(err, media.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error reading media", err)
+		return
+	}
+	defer blob.Close()
+	w.Header().Set("Content-Type", contentType)
+	// Whether the image is served depends on the viewer and on the chirp
+	// staying visible, so shared caches must not keep it.
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, blob); err != nil {
+		logger(r.Context()).WarnContext(r.Context(), "could not stream media", "media_id", id, "error", err)
+	}
+}
+
+// deleteAttachmentBlobs removes stored files once their rows are gone.
+// Failures only leave orphaned blobs behind, so they are logged and ignored.
+func (cfg *apiConfig) deleteAttachmentBlobs(ctx context.Context, attachments []database.ChirpAttachment) {
+	for _, a := range attachments {
+		for _, key := range []string{a.StorageKey, a.ThumbnailKey} {
+			if err := cfg.media.Delete(ctx, key); err != nil {
+				logger(ctx).WarnContext(ctx, "could not delete media", "key", key, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttachmentsFollowChirpVisibility(t *testing.T) {
+	s := loadSpec(t)
+	viewerID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	attachment := database.ChirpAttachment{
+		ID:                   uuid.New(),
+		CreatedAt:            now,
+		ChirpID:              uuid.New(),
+		UserID:               uuid.New(),
+		ContentType:          "image/png",
+		SizeBytes:            5,
+		StorageKey:           "media/original",
+		ThumbnailKey:         "media/thumbnail",
+		ThumbnailContentType: "image/png",
+	}
+
+	get := func(t *testing.T, ts *testServer, bearer bool) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/media/"+attachment.ID.String(), nil)
+		if bearer {
+			req.Header.Set("Authorization", "Bearer "+token(t, viewerID))
+		}
+		pattern, rec := ts.do(req)
+		s.checkResponse(t, pattern, rec)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	t.Run("visible to the viewer", func(t *testing.T) {
+		ts := newTestServer(t)
+		if err := ts.cfg.media.Put(context.Background(), attachment.StorageKey, strings.NewReader("image"), attachment.ContentType); err != nil {
+			t.Fatal(err)
+		}
+		ts.mock.ExpectQuery("GetChirpAttachmentByID").
+			WithArgs(attachment.ID, viewerID.String()).
+			WillReturnRows(rows(attachment))
+		rec := get(t, ts, true)
+		if rec.Code != http.StatusOK || rec.Body.String() != "image" {
+			t.Fatalf("expected 200 with the image, got %d: %s", rec.Code, rec.Body)
+		}
+		if cc := rec.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "private") {
+			t.Errorf("expected a private Cache-Control, got %q", cc)
+		}
+	})
+
+	t.Run("hidden, shadow-banned or blocked", func(t *testing.T) {
+		// The query applies the same visibility rules as GetChirpByID, so a
+		// chirp the viewer cannot see yields no row.
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetChirpAttachmentByID").
+			WithArgs(attachment.ID, viewerID.String()).
+			WillReturnRows(emptyRows(database.ChirpAttachment{}))
+		if rec := get(t, ts, true); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("anonymous viewer", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetChirpAttachmentByID").
+			WithArgs(attachment.ID, nil).
+			WillReturnRows(emptyRows(database.ChirpAttachment{}))
+		if rec := get(t, ts, false); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestUploadAttachmentLimitsFailure(t *testing.T) {
+	s := loadSpec(t)
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: userID}
+
+	ts := newTestServer(t)
+	ts.mock.ExpectQuery("GetChirpByID").WillReturnRows(rows(chirp))
+	ts.mock.ExpectQuery("GetUserByID").WillReturnError(errors.New("connection reset"))
+	req := httptest.NewRequest("POST", "/api/chirps/"+chirp.ID.String()+"/media", strings.NewReader(""))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	req.Header.Set("Authorization", "Bearer "+token(t, userID))
+	pattern, rec := ts.do(req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body)
+	}
+	s.checkResponse(t, pattern, rec)
+	if err := ts.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audit actions. Keep these stable, they are what admins filter on.
+const (
+	auditLoginSuccess      = "login.success"
+	auditLoginFailure      = "login.failure"
+	auditTokenRefresh      = "token.refresh"
+	auditTokenRevoke       = "token.revoke"
+	auditEmailChange       = "user.email_change"
+	auditPasswordChange    = "user.password_change"
+	auditChirpDelete       = "chirp.delete"
+	auditPolkaUpgrade      = "polka.upgrade"
+	auditPolkaSubscription = "polka.subscription_change"
+	auditAdminReset        = "admin.reset"
+	auditReportResolve     = "admin.report_resolve"
+	auditUserSuspend       = "admin.user_suspend"
+	auditUserUnsuspend     = "admin.user_unsuspend"
+	auditUserShadowBan     = "admin.user_shadow_ban"
+	auditUserShadowBanLift = "admin.user_shadow_ban_lift"
+	auditWebhookReplay     = "admin.webhook_replay"
+	auditEndpointCreate    = "admin.webhook_endpoint_create"
+	auditEndpointDelete    = "admin.webhook_endpoint_delete"
+	auditEndpointRetry     = "admin.webhook_endpoint_retry"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+	auditExportPageSize  = 500
+)
+
+// auditEntry is one event to record. Target is optional.
+type auditEntry struct {
+	Action     string
+	Actor      uuid.NullUUID
+	TargetType string
+	TargetID   string
+	Metadata   map[string]any
+}
+
+// audit appends an event to the audit log. A failure to record is logged but
+// never fails the request that triggered it.
+func (cfg *apiConfig) audit(r *http.Request, e auditEntry) {
+	metadata := json.RawMessage("{}")
+	if len(e.Metadata) > 0 {
+		b, err := json.Marshal(e.Metadata)
+		if err != nil {
+			logger(r.Context()).ErrorContext(r.Context(), "could not encode audit metadata", "action", e.Action, "error", err)
+		} else {
+			metadata = b
+		}
+	}
+	err := cfg.db.CreateAuditEvent(r.Context(), database.CreateAuditEventParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now().UTC(),
+		Action:     e.Action,
+		ActorID:    e.Actor,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Ip:         cfg.clientIP(r),
+		UserAgent:  r.UserAgent(),
+		Metadata:   metadata,
+	})
+	if err != nil {
+		logger(r.Context()).ErrorContext(r.Context(), "could not record audit event", "action", e.Action, "error", err)
+	}
+}
+
+func actor(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+type auditEvent struct {
+	ID         uuid.UUID       `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Action     string          `json:"action"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+func auditEventFromRow(e database.AuditEvent) auditEvent {
+	ev := auditEvent{
+		ID:         e.ID,
+		CreatedAt:  e.CreatedAt,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.Ip,
+		UserAgent:  e.UserAgent,
+		Metadata:   e.Metadata,
+	}
+	if e.ActorID.Valid {
+		ev.ActorID = &e.ActorID.UUID
+	}
+	return ev
+}
+
+// auditFilter reads the filter query parameters shared by the list and
+// export endpoints: action, actor_id, target_id, since and until (RFC 3339).
+func auditFilter(r *http.Request) (database.ListAuditEventsParams, error) {
+	q := r.URL.Query()
+	p := database.ListAuditEventsParams{}
+	if v := q.Get("action"); v != "" {
+		p.Action = sql.NullString{String: v, Valid: true}
+	}
+	if v := q.Get("actor_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return p, errors.New("actor_id must be a UUID")
+		}
+		p.ActorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if v := q.Get("target_id"); v != "" {
+		p.TargetID = sql.NullString{String: v, Valid: true}
+	}
+	for _, f := range []struct {
+		name string
+		dst  *sql.NullTime
+	}{{"since", &p.Since}, {"until", &p.Until}} {
+		v := q.Get(f.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return p, errors.New(f.name + " must be an RFC 3339 timestamp")
+		}
+		*f.dst = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+	return p, nil
+}
+
+// Cursors point at the last event of a page so the next page starts after
+// it. They are opaque to clients.
+func encodeAuditCursor(e database.AuditEvent) string {
+	raw := strconv.FormatInt(e.CreatedAt.UnixNano(), 10) + "." + e.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeAuditCursor(cursor string, p *database.ListAuditEventsParams) error {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return err
+	}
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return errors.New("malformed cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return err
+	}
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+	p.BeforeCreatedAt = sql.NullTime{Time: time.Unix(0, n).UTC(), Valid: true}
+	p.BeforeID = uuid.NullUUID{UUID: uid, Valid: true}
+	return nil
+}
+
+func (cfg *apiConfig) handlerListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireModerator(w, r); !ok {
+		return
+	}
+	p, err := auditFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	limit := defaultAuditPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditPageSize {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 200", err)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		if err := decodeAuditCursor(v, &p); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor", err)
+			return
+		}
+	}
+	// Fetch one extra row to know whether there is another page.
+	p.RowLimit = int32(limit + 1)
+	rows, err := cfg.db.ListAuditEvents(r.Context(), p)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	type page struct {
+		Events     []auditEvent `json:"events"`
+		NextCursor string       `json:"next_cursor,omitempty"`
+	}
+	res := page{Events: []auditEvent{}}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		res.NextCursor = encodeAuditCursor(rows[len(rows)-1])
+	}
+	for _, e := range rows {
+		res.Events = append(res.Events, auditEventFromRow(e))
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+// handlerExportAuditEvents streams every matching event as newline delimited
+// JSON, newest first.
+func (cfg *apiConfig) handlerExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireModerator(w, r); !ok {
+		return
+	}
+	p, err := auditFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	p.RowLimit = auditExportPageSize
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.ndjson"`)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	for {
+		rows, err := cfg.db.ListAuditEvents(r.Context(), p)
+		if err != nil {
+			// Headers are already sent, so all we can do is stop.
+			logger(r.Context()).ErrorContext(r.Context(), "audit export failed", "error", err)
+			return
+		}
+		for _, e := range rows {
+			if err := enc.Encode(auditEventFromRow(e)); err != nil {
+				return
+			}
+		}
+		if len(rows) < auditExportPageSize {
+			return
+		}
+		last := rows[len(rows)-1]
+		p.BeforeCreatedAt = sql.NullTime{Time: last.CreatedAt, Valid: true}
+		p.BeforeID = uuid.NullUUID{UUID: last.ID, Valid: true}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+// relationTarget authenticates the caller and parses the {userID} path value
+// for the block and mute endpoints. It writes the error response itself and
+// reports whether the handler may continue.
+func (cfg *apiConfig) relationTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, targetID, true
+}
+
+func (cfg *apiConfig) handlerBlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "you cannot block yourself", nil)
+		return
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), targetID); err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue blocking user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	err = qtx.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue blocking user", err)
+		return
+	}
+	err = qtx.RemoveFollowsBetween(r.Context(), database.RemoveFollowsBetweenParams{
+		UserA: userID,
+		UserB: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue blocking user", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue blocking user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+	err := cfg.db.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue unblocking user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "you cannot mute yourself", nil)
+		return
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), targetID); err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	err := cfg.db.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID:   userID,
+		MutedID:   targetID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue muting user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+	err := cfg.db.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue unmuting user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+func (cfg *apiConfig) handlerRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	// A chirp a moderator removed stays removed; the author may not undo it.
+	// RestoreChirp checks this too, in case the moderator acts in between.
+	if c, err := cfg.db.GetChirpByIDIncludingDeleted(r.Context(), chirpID); err == nil && c.UserID == userID && c.DeletedByModerator {
+		respondWithProblem(w, problem.New(http.StatusForbidden, "removed_by_moderator", "this chirp was removed by a moderator and cannot be restored", nil))
+		return
+	}
+	restored, err := cfg.db.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:           chirpID,
+		UserID:       userID,
+		DeletedAfter: time.Now().Add(-cfg.restoreWindow),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "no deleted chirp to restore", err)
+		return
+	}
+	chrps, err := cfg.chirpResponses(r.Context(), []database.Chirp{restored})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, chrps[0])
+}
+
+// runPurgeJob permanently removes chirps that were soft deleted longer ago
+// than the restore window, along with their stored media. Chirps a moderator
+// removed are kept so the reports against them survive.
+func (cfg *apiConfig) runPurgeJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cfg.purgeDeletedChirps(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) {
+	cutoff := time.Now().Add(-cfg.restoreWindow)
+	attachments, err := cfg.db.GetAttachmentsForPurge(ctx, cutoff)
+	if err != nil {
+		slog.ErrorContext(ctx, "purge: could not read attachments", "error", err)
+		return
+	}
+	purged, err := cfg.db.PurgeDeletedChirps(ctx, cutoff)
+	if err != nil {
+		slog.ErrorContext(ctx, "purge: could not delete chirps", "error", err)
+		return
+	}
+	cfg.deleteAttachmentBlobs(ctx, attachments)
+	if len(purged) > 0 {
+		slog.InfoContext(ctx, "purge: removed deleted chirps", "count", len(purged))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestoreChirp(t *testing.T) {
+	s := loadSpec(t)
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	deleted := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      "oops",
+		UserID:    userID,
+		DeletedAt: sql.NullTime{Time: now, Valid: true},
+	}
+	removed := deleted
+	removed.DeletedByModerator = true
+
+	restore := func(t *testing.T, ts *testServer, c database.Chirp) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/chirps/"+c.ID.String()+"/restore", nil)
+		req.Header.Set("Authorization", "Bearer "+token(t, userID))
+		pattern, rec := ts.do(req)
+		s.checkResponse(t, pattern, rec)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	t.Run("deleted by the author", func(t *testing.T) {
+		ts := newTestServer(t)
+		restored := deleted
+		restored.DeletedAt = sql.NullTime{}
+		ts.mock.ExpectQuery("GetChirpByIDIncludingDeleted").WillReturnRows(rows(deleted))
+		ts.mock.ExpectQuery("RestoreChirp").WillReturnRows(rows(restored))
+		ts.mock.ExpectQuery("GetAttachmentsForChirps").WillReturnRows(emptyRows(database.ChirpAttachment{}))
+		if rec := restore(t, ts, deleted); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("removed by a moderator", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetChirpByIDIncludingDeleted").WillReturnRows(rows(removed))
+		rec := restore(t, ts, removed)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), `"code":"removed_by_moderator"`) {
+			t.Fatalf("expected 403 removed_by_moderator, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+func (cfg *apiConfig) handlerEditChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	type params struct {
+		Body string `json:"body" validate:"required"`
+	}
+	p := params{}
+	if err := cfg.decodeJSON(w, r, &p); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+	_, limits, err := cfg.limitsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	if !limits.EditChirps {
+		respondWithError(w, http.StatusForbidden, "editing chirps requires Chirpy Red", nil)
+		return
+	}
+	if utf8.RuneCountInString(p.Body) > limits.ChirpLength {
+		respondWithProblem(w, problem.Invalid("body", "too_long", fmt.Sprintf("Chirp is too long, the limit is %d characters", limits.ChirpLength)))
+		return
+	}
+	current, err := cfg.db.GetChirpByID(r.Context(), database.GetChirpByIDParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	if current.UserID != userID {
+		respondWithError(w, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
+		return
+	}
+	if time.Since(current.CreatedAt) > cfg.editWindow {
+		respondWithError(w, http.StatusForbidden, "the edit window for this chirp has passed", nil)
+		return
+	}
+	body := cfg.filter.Clean(p.Body)
+	if body == current.Body {
+		chrps, err := cfg.chirpResponses(r.Context(), []database.Chirp{current})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+			return
+		}
+		respondWithJson(w, http.StatusOK, chrps[0])
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue updating chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	now := time.Now()
+	_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ID:         uuid.New(),
+		ChirpID:    current.ID,
+		Body:       current.Body,
+		CreatedAt:  current.UpdatedAt,
+		ReplacedAt: now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue updating chirp", err)
+		return
+	}
+	// The update only applies if nobody edited the chirp since it was read,
+	// otherwise the revision above would not be the body being replaced.
+	updated, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		Body:        body,
+		UpdatedAt:   now,
+		ID:          current.ID,
+		UpdatedAt_2: current.UpdatedAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithProblem(w, problem.New(http.StatusConflict, "edit_conflict", "the chirp was changed by another request, fetch it and try again", err))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue updating chirp", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue updating chirp", err)
+		return
+	}
+	chrps, err := cfg.chirpResponses(r.Context(), []database.Chirp{updated})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, chrps[0])
+}
+
+func (cfg *apiConfig) handlerGetChirpHistory(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	_, err = cfg.db.GetChirpByID(r.Context(), database.GetChirpByIDParams{
+		ID:       chirpID,
+		ViewerID: cfg.viewerID(r),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	revisions, err := cfg.db.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	type revision struct {
+		ID         uuid.UUID `json:"id"`
+		Body       string    `json:"body"`
+		CreatedAt  time.Time `json:"created_at"`
+		ReplacedAt time.Time `json:"replaced_at"`
+	}
+	res := []revision{}
+	for _, rev := range revisions {
+		res = append(res, revision{
+			ID:         rev.ID,
+			Body:       rev.Body,
+			CreatedAt:  rev.CreatedAt,
+			ReplacedAt: rev.ReplacedAt,
+		})
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEditChirp(t *testing.T) {
+	s := loadSpec(t)
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	red := database.User{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "red@example.com", IsChirpyRed: true}
+	current := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      "teh first draft",
+		UserID:    userID,
+	}
+	edited := current
+	edited.Body = "the first draft"
+	edited.UpdatedAt = now.Add(time.Second)
+
+	edit := func(t *testing.T, ts *testServer) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("PUT", "/api/chirps/"+current.ID.String(), strings.NewReader(`{"body":"the first draft"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token(t, userID))
+		pattern, rec := ts.do(req)
+		s.checkResponse(t, pattern, rec)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	t.Run("saves the old body as a revision", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(red))
+		ts.mock.ExpectQuery("GetChirpByID").WillReturnRows(rows(current))
+		ts.mock.ExpectBegin()
+		ts.mock.ExpectQuery("CreateChirpRevision").
+			WithArgs(sqlmock.AnyArg(), current.ID, current.Body, current.UpdatedAt, sqlmock.AnyArg()).
+			WillReturnRows(rows(database.ChirpRevision{ID: uuid.New(), ChirpID: current.ID, Body: current.Body, CreatedAt: now, ReplacedAt: now}))
+		ts.mock.ExpectQuery("UpdateChirpBody").
+			WithArgs("the first draft", sqlmock.AnyArg(), current.ID, current.UpdatedAt).
+			WillReturnRows(rows(edited))
+		ts.mock.ExpectCommit()
+		ts.mock.ExpectQuery("GetAttachmentsForChirps").WillReturnRows(emptyRows(database.ChirpAttachment{}))
+		rec := edit(t, ts)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"the first draft"`) {
+			t.Fatalf("expected 200 with the new body, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("loses to a concurrent edit", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(red))
+		ts.mock.ExpectQuery("GetChirpByID").WillReturnRows(rows(current))
+		ts.mock.ExpectBegin()
+		ts.mock.ExpectQuery("CreateChirpRevision").
+			WillReturnRows(rows(database.ChirpRevision{ID: uuid.New(), ChirpID: current.ID, Body: current.Body, CreatedAt: now, ReplacedAt: now}))
+		ts.mock.ExpectQuery("UpdateChirpBody").WillReturnRows(emptyRows(database.Chirp{}))
+		ts.mock.ExpectRollback()
+		rec := edit(t, ts)
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"code":"edit_conflict"`) {
+			t.Fatalf("expected 409 edit_conflict, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("requires Chirpy Red", func(t *testing.T) {
+		ts := newTestServer(t)
+		free := red
+		free.IsChirpyRed = false
+		ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(free))
+		if rec := edit(t, ts); rec.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("the user's limits cannot be loaded", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnError(errors.New("connection reset"))
+		if rec := edit(t, ts); rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"github.com/MattInReality/Chirpy/internal/validate"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// decodeJSON reads the request body into dst and checks it against the
+// validate tags on dst's fields. The body must be a single JSON value no
+// larger than max_body_bytes, sent as application/json (or with no
+// Content-Type at all), with no fields dst does not have. Every error it
+// returns is one respondWithProblem knows how to answer.
+func (cfg *apiConfig) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || mediaType != "application/json" {
+			return problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType, "Content-Type must be application/json", err)
+		}
+	}
+	body := r.Body
+	if cfg.maxBodyBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, cfg.maxBodyBytes)
+	}
+	d := json.NewDecoder(body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(dst); err != nil {
+		// encoding/json has no error type for unknown fields.
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return problem.Invalid(strings.Trim(field, `"`), "unknown_field", "is not a recognised field")
+		}
+		return err
+	}
+	if err := d.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidJSON, "request body must contain a single JSON value", err)
+	}
+	return validate.Struct(dst)
+}
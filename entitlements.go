@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/config"
+	"github.com/MattInReality/Chirpy/internal/entitlements"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// newEntitlements loads the per tier limits from the entitlements file,
+// falling back to the built in defaults.
+func newEntitlements(conf *config.Config) (*entitlements.Table, error) {
+	if conf.EntitlementsFile != "" {
+		return entitlements.LoadFile(conf.EntitlementsFile)
+	}
+	return entitlements.New(nil)
+}
+
+// limitsFor returns the tier and limits of a user.
+func (cfg *apiConfig) limitsFor(ctx context.Context, userID uuid.UUID) (entitlements.Tier, entitlements.Limits, error) {
+	user, err := cfg.getUser(ctx, userID)
+	if err != nil {
+		return "", entitlements.Limits{}, err
+	}
+	tier := entitlements.TierOf(user.IsChirpyRed)
+	return tier, cfg.entitlements.For(tier), nil
+}
+
+func (cfg *apiConfig) handlerGetEntitlements(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	tier, limits, err := cfg.limitsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	type response struct {
+		Tier   entitlements.Tier   `json:"tier"`
+		Limits entitlements.Limits `json:"limits"`
+	}
+	respondWithJson(w, http.StatusOK, response{Tier: tier, Limits: limits})
+}
@@ -115,3 +115,36 @@ func TestGetBearerToken(t *testing.T) {
 	}
 
 }
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "whsec"
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Unix(1_700_000_000, 0)
+	valid := SignPayload(secret, body, now)
+	type testCase struct {
+		Name   string
+		Header string
+		Body   []byte
+		Now    time.Time
+		Want   error
+	}
+	testCases := []testCase{
+		{Name: "Valid", Header: valid, Body: body, Now: now, Want: nil},
+		{Name: "Within tolerance", Header: valid, Body: body, Now: now.Add(4 * time.Minute), Want: nil},
+		{Name: "Rotated secret", Header: valid + ",v1=deadbeef", Body: body, Now: now, Want: nil},
+		{Name: "Missing", Header: "", Body: body, Now: now, Want: ErrSignatureMissing},
+		{Name: "No timestamp", Header: "v1=abc", Body: body, Now: now, Want: ErrSignatureMalformed},
+		{Name: "Too old", Header: valid, Body: body, Now: now.Add(10 * time.Minute), Want: ErrSignatureExpired},
+		{Name: "From the future", Header: valid, Body: body, Now: now.Add(-10 * time.Minute), Want: ErrSignatureExpired},
+		{Name: "Tampered body", Header: valid, Body: []byte(`{"event":"user.downgraded"}`), Now: now, Want: ErrSignatureMismatch},
+		{Name: "Wrong secret", Header: SignPayload("other", body, now), Body: body, Now: now, Want: ErrSignatureMismatch},
+	}
+	for _, c := range testCases {
+		t.Run(c.Name, func(t *testing.T) {
+			err := VerifySignature(c.Header, secret, c.Body, 5*time.Minute, c.Now)
+			if err != c.Want {
+				t.Errorf("VerifySignature() error = %v, want %v", err, c.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSignatureMissing   = errors.New("signature header missing")
+	ErrSignatureMalformed = errors.New("signature header malformed")
+	ErrSignatureExpired   = errors.New("signature timestamp outside tolerance")
+	ErrSignatureMismatch  = errors.New("signature does not match")
+)
+
+// SignPayload returns a signature header of the form "t=<unix>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix>.<body>" keyed with secret.
+func SignPayload(secret string, body []byte, at time.Time) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + ",v1=" + computeSignature(secret, ts, body)
+}
+
+// VerifySignature checks a header produced by SignPayload. The timestamp must
+// be within tolerance of now either way, which stops old deliveries from
+// being replayed. Several v1 values may be present while secrets are rotated.
+func VerifySignature(header, secret string, body []byte, tolerance time.Duration, now time.Time) error {
+	if header == "" {
+		return ErrSignatureMissing
+	}
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrSignatureMalformed
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	if ts == "" || len(sigs) == 0 {
+		return ErrSignatureMalformed
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrSignatureMalformed
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrSignatureExpired
+	}
+	want := []byte(computeSignature(secret, ts, body))
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), want) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+func computeSignature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
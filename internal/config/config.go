@@ -0,0 +1,423 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/MattInReality/Chirpy/internal/filter"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinSecretLength is the shortest JWT secret accepted. HS256 keys shorter
+// than the hash output are easier to brute force.
+const MinSecretLength = 32
+
+// Config is every setting the server needs.
+type Config struct {
+	Port               int
+	FilepathRoot       string
+	DBURL              string
+	Platform           string
+	JWTSecret          string
+	PolkaKey           string
+	PolkaWebhookSecret string
+
+	AccessTokenTTL     time.Duration
+	RefreshTokenTTL    time.Duration
+	ChirpEditWindow    time.Duration
+	ChirpRestoreWindow time.Duration
+
+	ProfanityWords []string
+	ProfanityFile  string
+	ProfanityMask  string
+
+	MediaBackend  string
+	MediaDir      string
+	MediaMaxBytes int64
+	S3Endpoint    string
+	S3Bucket      string
+	S3Region      string
+	S3AccessKey   string
+	S3SecretKey   string
+
+	RateLimitStore    string
+	TrustProxyHeaders bool
+	EntitlementsFile  string
+	MigrateOnStart    bool
+	LogLevel          string
+	LogFormat         string
+	TracingExporter   string
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	WebhookTimeout    time.Duration
+	// WebhookAllowPrivateNetworks lets outbound webhooks reach loopback and
+	// private addresses, for local development.
+	WebhookAllowPrivateNetworks bool
+	ShutdownTimeout             time.Duration
+	ShutdownDelay               time.Duration
+	HealthTimeout               time.Duration
+	MaxHeaderBytes              int
+	MaxBodyBytes                int64
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Config {
+	return Config{
+		Port:               8080,
+		FilepathRoot:       ".",
+		AccessTokenTTL:     time.Hour,
+		RefreshTokenTTL:    60 * 24 * time.Hour,
+		ChirpEditWindow:    15 * time.Minute,
+		ChirpRestoreWindow: 30 * 24 * time.Hour,
+		ProfanityMask:      string(filter.MaskFixed),
+		MediaBackend:       "local",
+		MediaDir:           "./media",
+		MediaMaxBytes:      5 << 20,
+		RateLimitStore:     "memory",
+		LogLevel:           "info",
+		LogFormat:          "json",
+		TracingExporter:    "none",
+		ReadHeaderTimeout:  10 * time.Second,
+		ReadTimeout:        30 * time.Second,
+		WriteTimeout:       60 * time.Second,
+		IdleTimeout:        2 * time.Minute,
+		WebhookTimeout:     10 * time.Second,
+		ShutdownTimeout:    30 * time.Second,
+		HealthTimeout:      2 * time.Second,
+		MaxHeaderBytes:     1 << 20,
+		MaxBodyBytes:       1 << 20,
+	}
+}
+
+// setting is one configurable value. Its key names it everywhere: as is in
+// the config file, upper case in the environment and with dashes as a flag.
+type setting struct {
+	key   string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+func (s setting) env() string  { return strings.ToUpper(s.key) }
+func (s setting) flag() string { return strings.ReplaceAll(s.key, "_", "-") }
+
+func stringSetting(key, usage string, field func(*Config) *string) setting {
+	return setting{key, usage, func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}}
+}
+
+func intSetting(key, usage string, field func(*Config) *int) setting {
+	return setting{key, usage, func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", v)
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func int64Setting(key, usage string, field func(*Config) *int64) setting {
+	return setting{key, usage, func(c *Config, v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", v)
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func boolSetting(key, usage string, field func(*Config) *bool) setting {
+	return setting{key, usage, func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", v)
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+func durationSetting(key, usage string, field func(*Config) *time.Duration) setting {
+	return setting{key, usage, func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 15m or 24h", v)
+		}
+		*field(c) = d
+		return nil
+	}}
+}
+
+func listSetting(key, usage string, field func(*Config) *[]string) setting {
+	return setting{key, usage, func(c *Config, v string) error {
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}}
+}
+
+var settings = []setting{
+	intSetting("port", "port to listen on", func(c *Config) *int { return &c.Port }),
+	stringSetting("filepath_root", "directory served under /app/", func(c *Config) *string { return &c.FilepathRoot }),
+	stringSetting("db_url", "Postgres connection string (required)", func(c *Config) *string { return &c.DBURL }),
+	stringSetting("platform", `"dev" enables the reset endpoint`, func(c *Config) *string { return &c.Platform }),
+	stringSetting("jwt_secret", "secret for signing access tokens, at least 32 bytes (required)", func(c *Config) *string { return &c.JWTSecret }),
+	stringSetting("polka_key", "API key Polka sends with webhooks", func(c *Config) *string { return &c.PolkaKey }),
+	stringSetting("polka_webhook_secret", "secret for verifying signed Polka webhooks", func(c *Config) *string { return &c.PolkaWebhookSecret }),
+	durationSetting("access_token_ttl", "how long access tokens last", func(c *Config) *time.Duration { return &c.AccessTokenTTL }),
+	durationSetting("refresh_token_ttl", "how long refresh tokens last", func(c *Config) *time.Duration { return &c.RefreshTokenTTL }),
+	durationSetting("chirp_edit_window", "how long after posting a chirp can be edited", func(c *Config) *time.Duration { return &c.ChirpEditWindow }),
+	durationSetting("chirp_restore_window", "how long a deleted chirp can be restored", func(c *Config) *time.Duration { return &c.ChirpRestoreWindow }),
+	listSetting("profanity_words", "comma separated words to mask in chirps", func(c *Config) *[]string { return &c.ProfanityWords }),
+	stringSetting("profanity_file", "file of words to mask, one per line", func(c *Config) *string { return &c.ProfanityFile }),
+	stringSetting("profanity_mask", "fixed, length or keep-first", func(c *Config) *string { return &c.ProfanityMask }),
+	stringSetting("media_backend", "local or s3", func(c *Config) *string { return &c.MediaBackend }),
+	stringSetting("media_dir", "directory for uploaded media with the local backend", func(c *Config) *string { return &c.MediaDir }),
+	int64Setting("media_max_bytes", "largest upload in bytes", func(c *Config) *int64 { return &c.MediaMaxBytes }),
+	stringSetting("s3_endpoint", "S3 endpoint URL", func(c *Config) *string { return &c.S3Endpoint }),
+	stringSetting("s3_bucket", "S3 bucket", func(c *Config) *string { return &c.S3Bucket }),
+	stringSetting("s3_region", "S3 region", func(c *Config) *string { return &c.S3Region }),
+	stringSetting("s3_access_key", "S3 access key", func(c *Config) *string { return &c.S3AccessKey }),
+	stringSetting("s3_secret_key", "S3 secret key", func(c *Config) *string { return &c.S3SecretKey }),
+	stringSetting("rate_limit_store", "memory or postgres", func(c *Config) *string { return &c.RateLimitStore }),
+	boolSetting("migrate_on_start", "apply pending database migrations before serving", func(c *Config) *bool { return &c.MigrateOnStart }),
+	stringSetting("log_level", "debug, info, warn or error", func(c *Config) *string { return &c.LogLevel }),
+	stringSetting("log_format", "json or text", func(c *Config) *string { return &c.LogFormat }),
+	stringSetting("tracing_exporter", "none, stdout or otlp", func(c *Config) *string { return &c.TracingExporter }),
+	boolSetting("trust_proxy_headers", "rate limit by X-Forwarded-For", func(c *Config) *bool { return &c.TrustProxyHeaders }),
+	stringSetting("entitlements_file", "JSON file overriding the per tier limits", func(c *Config) *string { return &c.EntitlementsFile }),
+	durationSetting("read_header_timeout", "how long to wait for request headers", func(c *Config) *time.Duration { return &c.ReadHeaderTimeout }),
+	durationSetting("read_timeout", "how long to wait for a whole request", func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationSetting("write_timeout", "how long a response may take", func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	durationSetting("idle_timeout", "how long to keep idle connections open", func(c *Config) *time.Duration { return &c.IdleTimeout }),
+	durationSetting("webhook_timeout", "how long to wait for an outbound webhook receiver", func(c *Config) *time.Duration { return &c.WebhookTimeout }),
+	boolSetting("webhook_allow_private_networks", "let outbound webhooks reach local and private addresses", func(c *Config) *bool { return &c.WebhookAllowPrivateNetworks }),
+	durationSetting("shutdown_timeout", "how long to let in-flight requests finish on shutdown", func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	durationSetting("shutdown_delay", "how long to keep serving with readiness failing before shutting down", func(c *Config) *time.Duration { return &c.ShutdownDelay }),
+	durationSetting("health_timeout", "how long each readiness check may take", func(c *Config) *time.Duration { return &c.HealthTimeout }),
+	intSetting("max_header_bytes", "largest request header block in bytes", func(c *Config) *int { return &c.MaxHeaderBytes }),
+	int64Setting("max_body_bytes", "largest request body in bytes, media uploads excepted", func(c *Config) *int64 { return &c.MaxBodyBytes }),
+}
+
+// Options says where Load looks for settings.
+type Options struct {
+	// Args are the command line arguments, without the program name.
+	Args []string
+	// LookupEnv reads the environment, usually os.LookupEnv.
+	LookupEnv func(string) (string, bool)
+	// DotEnv is the path of a .env file. A missing file is ignored.
+	DotEnv string
+}
+
+// Load reads the configuration. Later sources win: defaults, then the config
+// file, then the .env file, then the environment, then flags. The config file
+// is named by the -config flag or the CONFIG_FILE variable.
+func Load(opts Options) (*Config, error) {
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	configFile := fs.String("config", "", "YAML config file")
+	flags := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flags[s.key] = fs.String(s.flag(), "", s.usage)
+	}
+	if err := fs.Parse(opts.Args); err != nil {
+		return nil, err
+	}
+
+	dotenv := map[string]string{}
+	if opts.DotEnv != "" {
+		env, err := godotenv.Read(opts.DotEnv)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("reading %s: %w", opts.DotEnv, err)
+		}
+		if env != nil {
+			dotenv = env
+		}
+	}
+	lookup := func(key string) (string, bool) {
+		if opts.LookupEnv != nil {
+			if v, ok := opts.LookupEnv(key); ok {
+				return v, true
+			}
+		}
+		v, ok := dotenv[key]
+		return v, ok
+	}
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookup("CONFIG_FILE")
+	}
+	values := map[string]string{}
+	if path != "" {
+		fromFile, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		values = fromFile
+	}
+	for _, s := range settings {
+		if v, ok := lookup(s.env()); ok {
+			values[s.key] = v
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag() == f.Name {
+				values[s.key] = *flags[s.key]
+			}
+		}
+	})
+
+	c := Default()
+	var errs []error
+	for _, s := range settings {
+		v, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		if err := s.set(&c, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// readFile reads a flat YAML mapping of setting keys to values. Lists are
+// accepted for list settings.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]any{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[s.key] = true
+	}
+	values := make(map[string]string, len(raw))
+	for key, v := range raw {
+		if !known[key] {
+			return nil, fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		switch v := v.(type) {
+		case nil:
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+// Validate reports every setting that is missing or out of range.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if c.DBURL == "" {
+		fail("db_url is required")
+	}
+	if c.JWTSecret == "" {
+		fail("jwt_secret is required")
+	} else if len(c.JWTSecret) < MinSecretLength {
+		fail("jwt_secret must be at least %d bytes", MinSecretLength)
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		fail("port must be between 1 and 65535")
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"access_token_ttl", c.AccessTokenTTL},
+		{"refresh_token_ttl", c.RefreshTokenTTL},
+		{"chirp_edit_window", c.ChirpEditWindow},
+		{"chirp_restore_window", c.ChirpRestoreWindow},
+		{"read_header_timeout", c.ReadHeaderTimeout},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"idle_timeout", c.IdleTimeout},
+		{"webhook_timeout", c.WebhookTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"health_timeout", c.HealthTimeout},
+	} {
+		if d.value <= 0 {
+			fail("%s must be positive", d.name)
+		}
+	}
+	if c.RefreshTokenTTL <= c.AccessTokenTTL {
+		fail("refresh_token_ttl must be longer than access_token_ttl")
+	}
+	if _, err := filter.ParseMask(c.ProfanityMask); err != nil {
+		fail("profanity_mask: %v", err)
+	}
+	switch c.MediaBackend {
+	case "local":
+	case "s3":
+		if c.S3Bucket == "" || c.S3AccessKey == "" || c.S3SecretKey == "" {
+			fail("s3_bucket, s3_access_key and s3_secret_key are required with the s3 media backend")
+		}
+	default:
+		fail("media_backend must be local or s3")
+	}
+	if c.MediaMaxBytes <= 0 {
+		fail("media_max_bytes must be positive")
+	}
+	if c.ShutdownDelay < 0 {
+		fail("shutdown_delay must not be negative")
+	}
+	if c.MaxHeaderBytes <= 0 {
+		fail("max_header_bytes must be positive")
+	}
+	if c.MaxBodyBytes <= 0 {
+		fail("max_body_bytes must be positive")
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		fail("log_level must be debug, info, warn or error")
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		fail("log_format must be json or text")
+	}
+	switch c.TracingExporter {
+	case "none", "stdout", "otlp":
+	default:
+		fail("tracing_exporter must be none, stdout or otlp")
+	}
+	if c.RateLimitStore != "memory" && c.RateLimitStore != "postgres" {
+		fail("rate_limit_store must be memory or postgres")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "chirpy.yaml", `
+db_url: postgres://file
+port: 7000
+chirp_edit_window: 5m
+access_token_ttl: 30m
+profanity_words: [kerfuffle, fornax]
+`)
+	dotenv := writeFile(t, ".env", "PORT=7100\nCHIRP_EDIT_WINDOW=10m\nJWT_SECRET="+testSecret+"\n")
+	c, err := Load(Options{
+		Args:      []string{"-config", file, "-port", "7300"},
+		LookupEnv: env(map[string]string{"PORT": "7200", "ACCESS_TOKEN_TTL": "45m"}),
+		DotEnv:    dotenv,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DBURL != "postgres://file" {
+		t.Errorf("expected db_url from the file, got %q", c.DBURL)
+	}
+	if c.ChirpEditWindow != 10*time.Minute {
+		t.Errorf("expected .env to override the file, got %v", c.ChirpEditWindow)
+	}
+	if c.AccessTokenTTL != 45*time.Minute {
+		t.Errorf("expected the environment to override the file, got %v", c.AccessTokenTTL)
+	}
+	if c.Port != 7300 {
+		t.Errorf("expected the flag to win, got %d", c.Port)
+	}
+	if strings.Join(c.ProfanityWords, ",") != "kerfuffle,fornax" {
+		t.Errorf("expected the word list from the file, got %v", c.ProfanityWords)
+	}
+	if c.RefreshTokenTTL != Default().RefreshTokenTTL {
+		t.Errorf("expected unset values to keep their defaults, got %v", c.RefreshTokenTTL)
+	}
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	file := writeFile(t, "chirpy.yaml", "db_url: postgres://file\njwt_secret: "+testSecret+"\n")
+	c, err := Load(Options{LookupEnv: env(map[string]string{"CONFIG_FILE": file})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DBURL != "postgres://file" {
+		t.Fatalf("expected db_url from the file, got %q", c.DBURL)
+	}
+}
+
+func TestLoadRejectsBadConfig(t *testing.T) {
+	base := map[string]string{"DB_URL": "postgres://x", "JWT_SECRET": testSecret}
+	testCases := []struct {
+		Name string
+		Env  map[string]string
+		Want string
+	}{
+		{Name: "Missing secret", Env: map[string]string{"JWT_SECRET": ""}, Want: "jwt_secret is required"},
+		{Name: "Short secret", Env: map[string]string{"JWT_SECRET": "hunter2"}, Want: "at least 32 bytes"},
+		{Name: "Missing database", Env: map[string]string{"DB_URL": ""}, Want: "db_url is required"},
+		{Name: "Bad duration", Env: map[string]string{"CHIRP_EDIT_WINDOW": "ten minutes"}, Want: "chirp_edit_window"},
+		{Name: "Bad port", Env: map[string]string{"PORT": "99999"}, Want: "port must be between"},
+		{Name: "Bad backend", Env: map[string]string{"MEDIA_BACKEND": "ftp"}, Want: "media_backend"},
+		{Name: "S3 without bucket", Env: map[string]string{"MEDIA_BACKEND": "s3"}, Want: "s3_bucket"},
+		{Name: "Refresh shorter than access", Env: map[string]string{"REFRESH_TOKEN_TTL": "1m"}, Want: "refresh_token_ttl"},
+		{Name: "Bad log level", Env: map[string]string{"LOG_LEVEL": "loud"}, Want: "log_level"},
+		{Name: "Bad tracing exporter", Env: map[string]string{"TRACING_EXPORTER": "zipkin"}, Want: "tracing_exporter"},
+		{Name: "Zero body limit", Env: map[string]string{"MAX_BODY_BYTES": "0"}, Want: "max_body_bytes"},
+		{Name: "Negative drain", Env: map[string]string{"SHUTDOWN_TIMEOUT": "-1s"}, Want: "shutdown_timeout"},
+	}
+	for _, c := range testCases {
+		t.Run(c.Name, func(t *testing.T) {
+			vars := map[string]string{}
+			for k, v := range base {
+				vars[k] = v
+			}
+			for k, v := range c.Env {
+				vars[k] = v
+			}
+			_, err := Load(Options{LookupEnv: env(vars)})
+			if err == nil || !strings.Contains(err.Error(), c.Want) {
+				t.Fatalf("expected an error containing %q, got %v", c.Want, err)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsUnknownFileSetting(t *testing.T) {
+	file := writeFile(t, "chirpy.yaml", "jwt_secrte: nope\n")
+	if _, err := Load(Options{Args: []string{"-config", file}}); err == nil {
+		t.Fatal("expected an unknown setting to be rejected")
+	}
+}
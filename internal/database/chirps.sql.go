@@ -7,9 +7,11 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
@@ -17,7 +19,7 @@ INSERT INTO chirps (
   id, created_at, updated_at, body, user_id
 ) VALUES (
   $1, $2, $3, $4, $5
-  ) RETURNING id, created_at, updated_at, body, user_id
+  ) RETURNING id, created_at, updated_at, body, user_id, edited_at, deleted_at, hidden_at, deleted_by_moderator
 `
 
 type CreateChirpParams struct {
@@ -43,21 +45,35 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
 	)
 	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :one
-DELETE FROM chirps WHERE id = $1 AND user_id = $2 RETURNING id, created_at, updated_at, body, user_id
+const getChirpByID = `-- name: GetChirpByID :one
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM chirps
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1
+AND chirps.deleted_at IS NULL
+AND chirps.hidden_at IS NULL
+AND (users.shadow_banned_at IS NULL OR chirps.user_id = $2)
+AND NOT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE (blocks.blocker_id = $2 AND blocks.blocked_id = chirps.user_id)
+  OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2)
+)
 `
 
-type DeleteChirpParams struct {
-	ID     uuid.UUID
-	UserID uuid.UUID
+type GetChirpByIDParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
 }
 
-func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, deleteChirp, arg.ID, arg.UserID)
+func (q *Queries) GetChirpByID(ctx context.Context, arg GetChirpByIDParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, arg.ID, arg.ViewerID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -65,18 +81,20 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
 	)
 	return i, err
 }
 
-const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-WHERE id = $1
+const getChirpByIDIncludingDeleted = `-- name: GetChirpByIDIncludingDeleted :one
+SELECT id, created_at, updated_at, body, user_id, edited_at, deleted_at, hidden_at, deleted_by_moderator FROM chirps WHERE id = $1
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+func (q *Queries) GetChirpByIDIncludingDeleted(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIDIncludingDeleted, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -84,18 +102,73 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-ORDER BY created_at
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM chirps
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirps.deleted_at IS NULL
+AND chirps.hidden_at IS NULL
+AND (users.shadow_banned_at IS NULL OR chirps.user_id = $1)
+AND NOT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = chirps.user_id)
+  OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1)
+)
+AND NOT EXISTS (
+  SELECT 1 FROM mutes
+  WHERE mutes.muter_id = $1
+  AND mutes.muted_id = chirps.user_id
+)
+ORDER BY chirps.created_at
+`
+
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDsIncludingDeleted = `-- name: GetChirpsByIDsIncludingDeleted :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, deleted_at, hidden_at, deleted_by_moderator FROM chirps
+WHERE id = ANY($1::uuid[])
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirpsByIDsIncludingDeleted(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDsIncludingDeleted, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -109,6 +182,10 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
 		); err != nil {
 			return nil, err
 		}
@@ -124,13 +201,70 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-WHERE user_id = $1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM chirps
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1
+AND chirps.deleted_at IS NULL
+AND chirps.hidden_at IS NULL
+AND (users.shadow_banned_at IS NULL OR chirps.user_id = $2)
+AND NOT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE (blocks.blocker_id = $2 AND blocks.blocked_id = chirps.user_id)
+  OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2)
+)
+AND NOT EXISTS (
+  SELECT 1 FROM mutes
+  WHERE mutes.muter_id = $2
+  AND mutes.muted_id = chirps.user_id
+)
+`
+
+type GetChirpsByUserIDParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetChirpsByUserID(ctx context.Context, arg GetChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeletedChirps = `-- name: GetDeletedChirps :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, deleted_at, hidden_at, deleted_by_moderator FROM chirps
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
 `
 
-func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, userID)
+func (q *Queries) GetDeletedChirps(ctx context.Context) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getDeletedChirps)
 	if err != nil {
 		return nil, err
 	}
@@ -144,6 +278,10 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
 		); err != nil {
 			return nil, err
 		}
@@ -157,3 +295,171 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps SET
+  hidden_at = $1
+WHERE id = $2
+`
+
+type HideChirpParams struct {
+	HiddenAt sql.NullTime
+	ID       uuid.UUID
+}
+
+func (q *Queries) HideChirp(ctx context.Context, arg HideChirpParams) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, arg.HiddenAt, arg.ID)
+	return err
+}
+
+const moderatorDeleteChirp = `-- name: ModeratorDeleteChirp :exec
+UPDATE chirps SET
+  deleted_at = COALESCE(deleted_at, $1),
+  deleted_by_moderator = true
+WHERE id = $2
+`
+
+type ModeratorDeleteChirpParams struct {
+	DeletedAt sql.NullTime
+	ID        uuid.UUID
+}
+
+func (q *Queries) ModeratorDeleteChirp(ctx context.Context, arg ModeratorDeleteChirpParams) error {
+	_, err := q.db.ExecContext(ctx, moderatorDeleteChirp, arg.DeletedAt, arg.ID)
+	return err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :many
+DELETE FROM chirps
+WHERE deleted_at < $1::timestamp
+AND NOT deleted_by_moderator
+RETURNING id
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps SET
+  deleted_at = NULL
+WHERE id = $1
+AND user_id = $2
+AND deleted_at > $3::timestamp
+AND NOT deleted_by_moderator
+RETURNING id, created_at, updated_at, body, user_id, edited_at, deleted_at, hidden_at, deleted_by_moderator
+`
+
+type RestoreChirpParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
+	)
+	return i, err
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :one
+UPDATE chirps SET
+  deleted_at = $1
+WHERE id = $2
+AND user_id = $3
+AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, edited_at, deleted_at, hidden_at, deleted_by_moderator
+`
+
+type SoftDeleteChirpParams struct {
+	DeletedAt sql.NullTime
+	ID        uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, arg SoftDeleteChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteChirp, arg.DeletedAt, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps SET
+  body = $1,
+  updated_at = $2,
+  edited_at = $2
+WHERE id = $3
+AND updated_at = $4
+AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, edited_at, deleted_at, hidden_at, deleted_by_moderator
+`
+
+type UpdateChirpBodyParams struct {
+	Body        string
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+	UpdatedAt_2 time.Time
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody,
+		arg.Body,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.UpdatedAt_2,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
+	)
+	return i, err
+}
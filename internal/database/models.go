@@ -6,17 +6,104 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	Action     string
+	ActorID    uuid.NullUUID
+	TargetType string
+	TargetID   string
+	Ip         string
+	UserAgent  string
+	Metadata   json.RawMessage
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Body               string
+	UserID             uuid.UUID
+	EditedAt           sql.NullTime
+	DeletedAt          sql.NullTime
+	HiddenAt           sql.NullTime
+	DeletedByModerator bool
+}
+
+type ChirpAttachment struct {
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	ChirpID              uuid.UUID
+	UserID               uuid.UUID
+	ContentType          string
+	SizeBytes            int64
+	Width                int32
+	Height               int32
+	StorageKey           string
+	ThumbnailKey         string
+	ThumbnailContentType string
+}
+
+type ChirpReport struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ChirpID        uuid.UUID
+	ReporterID     uuid.UUID
+	Reason         string
+	Details        string
+	Status         string
+	Resolution     sql.NullString
+	ResolutionNote string
+	ResolvedBy     uuid.NullUUID
+	ResolvedAt     sql.NullTime
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
 	UserID    uuid.UUID
+	Kind      string
+	Message   string
+	ReadAt    sql.NullTime
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,6 +115,19 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 }
 
+type Subscription struct {
+	ID                 uuid.UUID
+	UserID             uuid.UUID
+	Plan               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	CancelAtPeriodEnd  bool
+	CanceledAt         sql.NullTime
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +135,62 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	Location       string
+	Website        string
+	AvatarUrl      string
+	IsModerator    bool
+	SuspendedAt    sql.NullTime
+	SuspendedUntil sql.NullTime
+	ShadowBannedAt sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	EndpointID    uuid.UUID
+	Event         string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	DeliveredAt   sql.NullTime
+	Traceparent   string
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          uuid.UUID
+	DeliveryID  uuid.UUID
+	AttemptedAt time.Time
+	StatusCode  int32
+	Error       string
+	DurationMs  int32
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID
+	UserID    uuid.NullUUID
+	Url       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	DeliveryID  string
+	Source      string
+	Event       string
+	Payload     json.RawMessage
+	Status      string
+	Error       string
+	Attempts    int32
+	ReceivedAt  time.Time
+	ProcessedAt sql.NullTime
+	ClaimedAt   sql.NullTime
 }
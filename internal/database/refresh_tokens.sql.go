@@ -56,7 +56,7 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red FROM users
+SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red, users.handle, users.display_name, users.bio, users.location, users.website, users.avatar_url, users.is_moderator, users.suspended_at, users.suspended_until, users.shadow_banned_at FROM users
 INNER JOIN refresh_tokens ON users.id = refresh_tokens.user_id
 WHERE token = $1
 AND revoked_at IS NULL
@@ -73,6 +73,16 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
@@ -94,3 +104,22 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, arg RevokeRefreshToken
 	_, err := q.db.ExecContext(ctx, revokeRefreshToken, arg.RevokedAt, arg.UpdatedAt, arg.Token)
 	return err
 }
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens SET
+  revoked_at = $1,
+  updated_at = $2
+WHERE user_id = $3
+AND revoked_at IS NULL
+`
+
+type RevokeUserRefreshTokensParams struct {
+	RevokedAt sql.NullTime
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, arg RevokeUserRefreshTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, arg.RevokedAt, arg.UpdatedAt, arg.UserID)
+	return err
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -64,8 +65,98 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const getPublicProfileByHandle = `-- name: GetPublicProfileByHandle :one
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio,
+  users.location, users.website, users.avatar_url, users.is_chirpy_red,
+  (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL) AS chirp_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE users.handle = $1::text
+`
+
+type GetPublicProfileByHandleRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	Location       string
+	Website        string
+	AvatarUrl      string
+	IsChirpyRed    bool
+	ChirpCount     int64
+	FollowerCount  int64
+	FollowingCount int64
+}
+
+func (q *Queries) GetPublicProfileByHandle(ctx context.Context, handle string) (GetPublicProfileByHandleRow, error) {
+	row := q.db.QueryRowContext(ctx, getPublicProfileByHandle, handle)
+	var i GetPublicProfileByHandleRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.IsChirpyRed,
+		&i.ChirpCount,
+		&i.FollowerCount,
+		&i.FollowingCount,
+	)
+	return i, err
+}
+
+const getPublicProfileByID = `-- name: GetPublicProfileByID :one
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio,
+  users.location, users.website, users.avatar_url, users.is_chirpy_red,
+  (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL) AS chirp_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE users.id = $1
+`
+
+type GetPublicProfileByIDRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	Location       string
+	Website        string
+	AvatarUrl      string
+	IsChirpyRed    bool
+	ChirpCount     int64
+	FollowerCount  int64
+	FollowingCount int64
+}
+
+func (q *Queries) GetPublicProfileByID(ctx context.Context, id uuid.UUID) (GetPublicProfileByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getPublicProfileByID, id)
+	var i GetPublicProfileByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.IsChirpyRed,
+		&i.ChirpCount,
+		&i.FollowerCount,
+		&i.FollowingCount,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, website, avatar_url, is_moderator, suspended_at, suspended_until, shadow_banned_at FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -78,12 +169,22 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE id = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, website, avatar_url, is_moderator, suspended_at, suspended_until, shadow_banned_at FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
@@ -96,17 +197,73 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
 
+const setUserShadowBan = `-- name: SetUserShadowBan :exec
+UPDATE users SET
+  shadow_banned_at = $1
+WHERE id = $2
+`
+
+type SetUserShadowBanParams struct {
+	ShadowBannedAt sql.NullTime
+	ID             uuid.UUID
+}
+
+func (q *Queries) SetUserShadowBan(ctx context.Context, arg SetUserShadowBanParams) error {
+	_, err := q.db.ExecContext(ctx, setUserShadowBan, arg.ShadowBannedAt, arg.ID)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users SET
+  suspended_at = $1,
+  suspended_until = $2
+WHERE id = $3
+`
+
+type SuspendUserParams struct {
+	SuspendedAt    sql.NullTime
+	SuspendedUntil sql.NullTime
+	ID             uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.SuspendedAt, arg.SuspendedUntil, arg.ID)
+	return err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :exec
+UPDATE users SET
+  suspended_at = NULL,
+  suspended_until = NULL
+WHERE id = $1
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users SET
   email = $1,
   hashed_password = $2,
   updated_at = $3
 WHERE id = $4
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, website, avatar_url, is_moderator, suspended_at, suspended_until, shadow_banned_at
 `
 
 type UpdateUserParams struct {
@@ -131,17 +288,73 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
 
-const upgradeToRedByID = `-- name: UpgradeToRedByID :exec
+const updateUserProfile = `-- name: UpdateUserProfile :one
 UPDATE users SET
-  is_chirpy_red = true
-WHERE id = $1
+  handle = $1,
+  display_name = $2,
+  bio = $3,
+  location = $4,
+  website = $5,
+  avatar_url = $6,
+  updated_at = $7
+WHERE id = $8
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, website, avatar_url, is_moderator, suspended_at, suspended_until, shadow_banned_at
 `
 
-func (q *Queries) UpgradeToRedByID(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeToRedByID, id)
-	return err
+type UpdateUserProfileParams struct {
+	Handle      sql.NullString
+	DisplayName string
+	Bio         string
+	Location    string
+	Website     string
+	AvatarUrl   string
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.Website,
+		arg.AvatarUrl,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
+	)
+	return i, err
 }
@@ -0,0 +1,103 @@
+package entitlements
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Tier is a level of account. Every user is on exactly one tier.
+type Tier string
+
+const (
+	Free Tier = "free"
+	Red  Tier = "red"
+)
+
+// Limits are what a tier is allowed to do.
+type Limits struct {
+	// ChirpLength is the longest chirp body, in characters.
+	ChirpLength int `json:"chirp_length"`
+	// EditChirps allows editing a chirp within the edit window.
+	EditChirps bool `json:"edit_chirps"`
+	// MediaPerChirp is how many attachments a chirp can have.
+	MediaPerChirp int `json:"media_per_chirp"`
+	// RateLimitMultiplier scales the per user limit of every rate limit
+	// policy.
+	RateLimitMultiplier int `json:"rate_limit_multiplier"`
+}
+
+// Defaults are used for any tier or field a configuration leaves out.
+var Defaults = map[Tier]Limits{
+	Free: {ChirpLength: 140, EditChirps: false, MediaPerChirp: 4, RateLimitMultiplier: 1},
+	Red:  {ChirpLength: 1000, EditChirps: true, MediaPerChirp: 10, RateLimitMultiplier: 2},
+}
+
+// Table holds the limits of every tier.
+type Table struct {
+	tiers map[Tier]Limits
+}
+
+func New(tiers map[Tier]Limits) (*Table, error) {
+	t := &Table{tiers: make(map[Tier]Limits, len(Defaults))}
+	for tier, l := range Defaults {
+		t.tiers[tier] = l
+	}
+	for tier, l := range tiers {
+		if _, ok := Defaults[tier]; !ok {
+			return nil, fmt.Errorf("unknown tier %q", tier)
+		}
+		if l.ChirpLength < 1 || l.MediaPerChirp < 0 || l.RateLimitMultiplier < 1 {
+			return nil, fmt.Errorf("tier %q: chirp_length and rate_limit_multiplier must be positive and media_per_chirp not negative", tier)
+		}
+		t.tiers[tier] = l
+	}
+	return t, nil
+}
+
+// Load reads a JSON object keyed by tier. Fields left out of a tier keep
+// their default values.
+func Load(r io.Reader) (*Table, error) {
+	raw := map[Tier]json.RawMessage{}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	tiers := make(map[Tier]Limits, len(raw))
+	for tier, msg := range raw {
+		l := Defaults[tier]
+		dec := json.NewDecoder(bytes.NewReader(msg))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&l); err != nil {
+			return nil, fmt.Errorf("tier %q: %w", tier, err)
+		}
+		tiers[tier] = l
+	}
+	return New(tiers)
+}
+
+func LoadFile(path string) (*Table, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(file)
+}
+
+// For returns the limits of tier. Unknown tiers get the free limits.
+func (t *Table) For(tier Tier) Limits {
+	if l, ok := t.tiers[tier]; ok {
+		return l
+	}
+	return t.tiers[Free]
+}
+
+// TierOf returns the tier of a user.
+func TierOf(isChirpyRed bool) Tier {
+	if isChirpyRed {
+		return Red
+	}
+	return Free
+}
@@ -0,0 +1,52 @@
+package entitlements
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	table, err := Load(strings.NewReader(`{"red": {"chirp_length": 500}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := table.For(Red)
+	if red.ChirpLength != 500 {
+		t.Fatalf("expected red chirp length 500, got %d", red.ChirpLength)
+	}
+	if red.MediaPerChirp != Defaults[Red].MediaPerChirp || !red.EditChirps {
+		t.Fatalf("expected fields left out to keep their defaults, got %+v", red)
+	}
+	if table.For(Free) != Defaults[Free] {
+		t.Fatalf("expected free tier to keep its defaults, got %+v", table.For(Free))
+	}
+	if table.For("gold") != table.For(Free) {
+		t.Fatal("expected unknown tiers to get the free limits")
+	}
+}
+
+func TestLoadRejectsBadConfig(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Config string
+	}{
+		{Name: "Unknown tier", Config: `{"gold": {"chirp_length": 500}}`},
+		{Name: "Unknown field", Config: `{"red": {"chirp_lenght": 500}}`},
+		{Name: "Zero chirp length", Config: `{"free": {"chirp_length": 0}}`},
+		{Name: "Zero multiplier", Config: `{"red": {"rate_limit_multiplier": 0}}`},
+		{Name: "Not JSON", Config: `chirp_length: 500`},
+	}
+	for _, c := range testCases {
+		t.Run(c.Name, func(t *testing.T) {
+			if _, err := Load(strings.NewReader(c.Config)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestTierOf(t *testing.T) {
+	if TierOf(true) != Red || TierOf(false) != Free {
+		t.Fatal("expected Chirpy Red users on the red tier and everyone else on free")
+	}
+}
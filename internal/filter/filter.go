@@ -0,0 +1,207 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"io"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultWords is used when no word list has been configured.
+var DefaultWords = []string{"kerfuffle", "sharbert", "fornax"}
+
+// Mask controls how a matched word is replaced.
+type Mask string
+
+const (
+	// MaskFixed replaces every match with "****" regardless of its length.
+	MaskFixed Mask = "fixed"
+	// MaskLength replaces each character of the match with '*'.
+	MaskLength Mask = "length"
+	// MaskKeepFirst keeps the first character and masks the rest.
+	MaskKeepFirst Mask = "keep-first"
+)
+
+func ParseMask(s string) (Mask, error) {
+	switch m := Mask(s); m {
+	case "":
+		return MaskFixed, nil
+	case MaskFixed, MaskLength, MaskKeepFirst:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown mask strategy %q", s)
+}
+
+// Filter masks listed words in text. It is safe for concurrent use, and the
+// word list can be swapped with SetWords while requests are being served.
+type Filter struct {
+	mask  Mask
+	words atomic.Pointer[map[string]struct{}]
+}
+
+func New(words []string, mask Mask) *Filter {
+	f := &Filter{mask: mask}
+	f.SetWords(words)
+	return f
+}
+
+func (f *Filter) SetWords(words []string) {
+	set := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		if k := skeleton(w); k != "" {
+			set[k] = struct{}{}
+		}
+	}
+	f.words.Store(&set)
+}
+
+// Len reports how many distinct words are in the current list.
+func (f *Filter) Len() int {
+	return len(*f.words.Load())
+}
+
+// Clean returns text with every listed word masked. Everything between words,
+// including whitespace and punctuation, is left exactly as it was.
+func (f *Filter) Clean(text string) string {
+	words := *f.words.Load()
+	var b strings.Builder
+	b.Grow(len(text))
+	rest := text
+	for len(rest) > 0 {
+		start, end := nextWord(rest)
+		if start < 0 {
+			b.WriteString(rest)
+			break
+		}
+		b.WriteString(rest[:start])
+		word := rest[start:end]
+		if _, bad := words[skeleton(word)]; bad {
+			b.WriteString(f.maskWord(word))
+		} else {
+			b.WriteString(word)
+		}
+		rest = rest[end:]
+	}
+	return b.String()
+}
+
+func (f *Filter) maskWord(word string) string {
+	switch f.mask {
+	case MaskLength:
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	case MaskKeepFirst:
+		first, size := utf8.DecodeRuneInString(word)
+		return string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+	}
+	return "****"
+}
+
+// LoadWords reads a word list with one word per line. Blank lines and lines
+// starting with '#' are ignored.
+func LoadWords(r io.Reader) ([]string, error) {
+	var words []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, s.Err()
+}
+
+// nextWord finds the byte offsets of the first word in s, or -1 if there is
+// none. Words are runs of letters, digits, combining marks and the symbols
+// commonly used as letter substitutes.
+func nextWord(s string) (int, int) {
+	start := -1
+	for i, r := range s {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			return start, i
+		}
+	}
+	if start < 0 {
+		return -1, -1
+	}
+	return start, len(s)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '@' || r == '$'
+}
+
+var folder = cases.Fold()
+
+// skeleton reduces a word to the form used for matching: compatibility
+// normalised, case folded, stripped of accents, with homoglyphs and
+// leetspeak mapped back to plain latin letters.
+func skeleton(word string) string {
+	s := folder.String(norm.NFKD.String(word))
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if m, ok := lookalikes[r]; ok {
+			r = m
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// lookalikes maps digits, symbols and non-latin letters that are commonly
+// substituted for latin letters. 'l' and '1' both map to 'i' so that
+// "1", "l" and "i" are interchangeable in either the list or the text.
+var lookalikes = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'l': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'@': 'a',
+	'$': 's',
+	// Cyrillic
+	'а': 'a',
+	'в': 'b',
+	'е': 'e',
+	'к': 'k',
+	'м': 'm',
+	'н': 'h',
+	'о': 'o',
+	'р': 'p',
+	'с': 'c',
+	'т': 't',
+	'у': 'y',
+	'х': 'x',
+	'і': 'i',
+	'ј': 'j',
+	'ѕ': 's',
+	// Greek
+	'α': 'a',
+	'β': 'b',
+	'ε': 'e',
+	'ι': 'i',
+	'κ': 'k',
+	'ν': 'v',
+	'ο': 'o',
+	'ρ': 'p',
+	'τ': 't',
+	'υ': 'u',
+	'χ': 'x',
+}
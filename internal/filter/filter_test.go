@@ -0,0 +1,129 @@
+package filter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClean(t *testing.T) {
+	type testCase struct {
+		Name string
+		Mask Mask
+		Text string
+		Want string
+	}
+	testCases := []testCase{
+		{Name: "Plain word", Text: "what a kerfuffle", Want: "what a ****"},
+		{Name: "Mixed case", Text: "Kerfuffle indeed", Want: "**** indeed"},
+		{Name: "Trailing punctuation", Text: "Kerfuffle! Sharbert?", Want: "****! ****?"},
+		{Name: "Newline", Text: "kerfuffle\nnext line", Want: "****\nnext line"},
+		{Name: "Repeated spaces kept", Text: "a  kerfuffle   here", Want: "a  ****   here"},
+		{Name: "Leetspeak", Text: "k3rfuffl3 and f0rn4x and $harbert", Want: "**** and **** and ****"},
+		{Name: "Cyrillic homoglyphs", Text: "kеrfuffle", Want: "****"},
+		{Name: "Accents", Text: "kérfüffle", Want: "****"},
+		{Name: "Fullwidth letters", Text: "ｆｏｒｎａｘ", Want: "****"},
+		{Name: "Part of a longer word", Text: "kerfuffles are fine", Want: "kerfuffles are fine"},
+		{Name: "Clean text untouched", Text: "hello, world!", Want: "hello, world!"},
+		{Name: "Length mask", Mask: MaskLength, Text: "Fornax!", Want: "******!"},
+		{Name: "Keep first mask", Mask: MaskKeepFirst, Text: "Fornax!", Want: "F*****!"},
+	}
+	for _, c := range testCases {
+		t.Run(c.Name, func(t *testing.T) {
+			mask := c.Mask
+			if mask == "" {
+				mask = MaskFixed
+			}
+			f := New(DefaultWords, mask)
+			if got := f.Clean(c.Text); got != c.Want {
+				t.Errorf("Clean(%q) = %q, want %q", c.Text, got, c.Want)
+			}
+		})
+	}
+}
+
+func TestParseMask(t *testing.T) {
+	if m, err := ParseMask(""); err != nil || m != MaskFixed {
+		t.Errorf("ParseMask(\"\") = %v, %v, want fixed", m, err)
+	}
+	if _, err := ParseMask("sparkles"); err == nil {
+		t.Error("unknown mask was accepted")
+	}
+}
+
+func TestLoadWords(t *testing.T) {
+	words, err := LoadWords(strings.NewReader("# comment\nfoo\n\n  bar  \n"))
+	if err != nil {
+		t.Fatalf("LoadWords() error = %v", err)
+	}
+	if strings.Join(words, ",") != "foo,bar" {
+		t.Errorf("LoadWords() = %v, want [foo bar]", words)
+	}
+}
+
+func TestWatchReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("foo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	words, _ := LoadFile(path)
+	f := New(words, MaskFixed)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.Watch(ctx, path, nil, 10*time.Millisecond)
+
+	later := time.Now().Add(time.Second)
+	if err := os.WriteFile(path, []byte("bar\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, later, later)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if f.Clean("bar") == "****" {
+			if f.Clean("foo") != "foo" {
+				t.Error("old word still filtered after reload")
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("word list was not reloaded")
+}
+
+func TestWatchKeepsBaseWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("foo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	base := []string{"kerfuffle"}
+	words, _ := LoadFile(path)
+	f := New(append(base, words...), MaskFixed)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.Watch(ctx, path, base, 10*time.Millisecond)
+
+	later := time.Now().Add(time.Second)
+	if err := os.WriteFile(path, []byte("bar\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, later, later)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if f.Clean("bar") == "****" {
+			if f.Clean("kerfuffle") != "****" {
+				t.Error("configured word dropped by reload")
+			}
+			if f.Clean("foo") != "foo" {
+				t.Error("old word still filtered after reload")
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("word list was not reloaded")
+}
@@ -0,0 +1,46 @@
+package filter
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+func LoadFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadWords(file)
+}
+
+// Watch polls path every interval and swaps in base plus the file's words
+// whenever the file changes. base holds the words configured elsewhere, so
+// they survive every reload. The first poll always loads the file. A file that
+// fails to load leaves the current list in place.
+func (f *Filter) Watch(ctx context.Context, path string, base []string, interval time.Duration) {
+	var lastMod time.Time
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(lastMod) {
+			continue
+		}
+		words, err := LoadFile(path)
+		if err != nil {
+			slog.Error("could not reload filter words", "path", path, "error", err)
+			continue
+		}
+		lastMod = info.ModTime()
+		f.SetWords(append(append([]string{}, base...), words...))
+		slog.Info("reloaded filter words", "path", path, "count", f.Len())
+	}
+}
@@ -0,0 +1,180 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses reported for a check and for the whole report.
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDraining = "draining"
+)
+
+// Checker reports whether a dependency is usable. Check should give up when
+// ctx is done.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckFunc lets an ordinary function be used as a Checker.
+type CheckFunc func(ctx context.Context) error
+
+func (f CheckFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of one check. Error is logged rather than served:
+// the probes are public and error text can describe the internal network.
+type Result struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Error      string `json:"-"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of every check.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// OK reports whether the service should receive traffic.
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Registry holds the checks that decide readiness. Checks run concurrently,
+// each with its own timeout, so one hung dependency cannot stall the probe.
+type Registry struct {
+	timeout  time.Duration
+	mu       sync.RWMutex
+	checks   []namedChecker
+	draining atomic.Bool
+}
+
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a check. Checks are reported in the order they were added.
+func (reg *Registry) Register(name string, c Checker) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.checks = append(reg.checks, namedChecker{name: name, checker: c})
+}
+
+// Drain makes readiness fail from now on so load balancers stop sending new
+// requests while in-flight ones finish.
+func (reg *Registry) Drain() {
+	reg.draining.Store(true)
+}
+
+// Check runs every check and summarises them.
+func (reg *Registry) Check(ctx context.Context) Report {
+	reg.mu.RLock()
+	checks := append([]namedChecker{}, reg.checks...)
+	reg.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = reg.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, r := range results {
+		if r.Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	if reg.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (reg *Registry) run(ctx context.Context, c namedChecker) Result {
+	ctx, cancel := context.WithTimeout(ctx, reg.timeout)
+	defer cancel()
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.checker.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	r := Result{Name: c.name, Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		r.Status = StatusFailing
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// ReadyHandler responds 200 when every check passes and 503 otherwise. With
+// ?verbose set the response is the JSON Report; without it, just the status
+// text, which is all most probes look at. Failing checks are logged with
+// their errors.
+func (reg *Registry) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := reg.Check(r.Context())
+		for _, c := range report.Checks {
+			if c.Error != "" {
+				slog.WarnContext(r.Context(), "readiness check failing", "check", c.Name, "error", c.Error)
+			}
+		}
+		code := http.StatusOK
+		if !report.OK() {
+			code = http.StatusServiceUnavailable
+		}
+		write(w, r, code, report)
+	})
+}
+
+// LiveHandler responds 200 for as long as the process can serve requests. It
+// checks no dependencies: restarting the server would not fix a database
+// outage.
+func LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write(w, r, http.StatusOK, Report{Status: StatusOK, Checks: []Result{}})
+	})
+}
+
+func write(w http.ResponseWriter, r *http.Request, code int, report Report) {
+	w.Header().Set("Cache-Control", "no-store")
+	if !r.URL.Query().Has("verbose") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		w.Write([]byte(http.StatusText(code)))
+		return
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
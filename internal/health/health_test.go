@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ok(context.Context) error { return nil }
+
+func TestReadyWhenEveryCheckPasses(t *testing.T) {
+	reg := NewRegistry(time.Second)
+	reg.Register("database", CheckFunc(ok))
+	reg.Register("media", CheckFunc(ok))
+
+	rec := httptest.NewRecorder()
+	reg.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "OK" {
+		t.Fatalf("expected 200 OK, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestNotReadyReportsFailingCheck(t *testing.T) {
+	reg := NewRegistry(50 * time.Millisecond)
+	reg.Register("database", CheckFunc(func(context.Context) error { return errors.New("connection refused") }))
+	reg.Register("media", CheckFunc(ok))
+	reg.Register("slow", CheckFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	rec := httptest.NewRecorder()
+	reg.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/readyz?verbose", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ name, status string }{
+		{"database", StatusFailing},
+		{"media", StatusOK},
+		{"slow", StatusFailing},
+	}
+	if report.Status != StatusFailing || len(report.Checks) != len(want) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for i, w := range want {
+		got := report.Checks[i]
+		if got.Name != w.name || got.Status != w.status {
+			t.Errorf("check %d: expected %s %s, got %+v", i, w.name, w.status, got)
+		}
+	}
+	if strings.Contains(rec.Body.String(), "connection refused") {
+		t.Errorf("expected the error to stay out of the response, got %s", rec.Body)
+	}
+	if got := reg.Check(context.Background()).Checks[0].Error; got != "connection refused" {
+		t.Errorf("expected the error to be recorded, got %q", got)
+	}
+}
+
+func TestDrainFailsReadinessButNotLiveness(t *testing.T) {
+	reg := NewRegistry(time.Second)
+	reg.Register("database", CheckFunc(ok))
+	reg.Drain()
+
+	rec := httptest.NewRecorder()
+	reg.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected readiness to fail while draining, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	LiveHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected liveness to pass while draining, got %d", rec.Code)
+	}
+}
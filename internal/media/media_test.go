@@ -0,0 +1,212 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encoding png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeader returns a PNG that declares w by h pixels but has no image data.
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], w)
+	binary.BigEndian.PutUint32(ihdr[4:], h)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // RGBA
+	chunk := append([]byte("IHDR"), ihdr...)
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	type testCase struct {
+		Name        string
+		Data        []byte
+		MaxBytes    int64
+		Err         error
+		Width       int
+		Height      int
+		ThumbWidth  int
+		ThumbHeight int
+	}
+	large := testPNG(t, 800, 400)
+	testCases := []testCase{
+		{
+			Name:        "Small image keeps its size",
+			Data:        testPNG(t, 100, 50),
+			MaxBytes:    1 << 20,
+			Width:       100,
+			Height:      50,
+			ThumbWidth:  100,
+			ThumbHeight: 50,
+		},
+		{
+			Name:        "Large image is scaled down",
+			Data:        large,
+			MaxBytes:    1 << 20,
+			Width:       800,
+			Height:      400,
+			ThumbWidth:  320,
+			ThumbHeight: 160,
+		},
+		{
+			Name:     "Text is rejected",
+			Data:     []byte("definitely not an image"),
+			MaxBytes: 1 << 20,
+			Err:      ErrUnsupportedType,
+		},
+		{
+			Name:     "Over the size limit",
+			Data:     large,
+			MaxBytes: int64(len(large) - 1),
+			Err:      ErrTooLarge,
+		},
+		{
+			Name:     "Over the pixel limit",
+			Data:     pngHeader(100_000, 100_000),
+			MaxBytes: 1 << 20,
+			Err:      ErrTooLarge,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.Name, func(t *testing.T) {
+			p, err := Process(c.Data, c.MaxBytes)
+			if !errors.Is(err, c.Err) {
+				t.Fatalf("Process() error = %v, want %v", err, c.Err)
+			}
+			if c.Err != nil {
+				return
+			}
+			if p.ContentType != "image/png" || p.Extension != ".png" {
+				t.Errorf("content type %s (%s), want image/png", p.ContentType, p.Extension)
+			}
+			if p.Width != c.Width || p.Height != c.Height {
+				t.Errorf("dimensions %dx%d, want %dx%d", p.Width, p.Height, c.Width, c.Height)
+			}
+			thumb, err := png.DecodeConfig(bytes.NewReader(p.Thumbnail))
+			if err != nil {
+				t.Fatalf("decoding thumbnail: %v", err)
+			}
+			if thumb.Width != c.ThumbWidth || thumb.Height != c.ThumbHeight {
+				t.Errorf("thumbnail %dx%d, want %dx%d", thumb.Width, thumb.Height, c.ThumbWidth, c.ThumbHeight)
+			}
+		})
+	}
+}
+
+func testStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	key := "chirps/abc/def.png"
+	if err := store.Put(ctx, key, strings.NewReader("hello"), "image/png"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello" {
+		t.Errorf("Get() = %q, want %q", data, "hello")
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing blob should succeed, got %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	testStore(t, store)
+	if err := store.Put(context.Background(), "../escape", strings.NewReader("x"), ""); err == nil {
+		t.Error("key escaping the root was accepted")
+	}
+}
+
+// fakeS3 is a minimal in-memory stand-in for an S3 bucket that checks each
+// request carries a valid signature.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	store   *S3Store
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	check, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	f.store.sign(check, body)
+	if r.Header.Get("Authorization") != check.Header.Get("Authorization") {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		obj, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(obj)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	store := NewS3Store(srv.URL, "media", "us-east-1", "access", "secret")
+	fixed := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.now = func() time.Time { return fixed }
+	fake.store = store
+	testStore(t, store)
+
+	bad := NewS3Store(srv.URL, "media", "us-east-1", "access", "wrong")
+	bad.now = store.now
+	if err := bad.Put(context.Background(), "x.png", strings.NewReader("x"), ""); err == nil {
+		t.Error("request with the wrong secret was accepted")
+	}
+}
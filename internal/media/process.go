@@ -0,0 +1,113 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/image/draw"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+import _ "golang.org/x/image/webp"
+
+const thumbnailMaxSide = 320
+
+// maxPixels caps the dimensions of an image before it is decoded. A small,
+// highly compressed file can declare dimensions that would need gigabytes of
+// memory to decode.
+const maxPixels = 40_000_000
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrTooLarge        = errors.New("media is too large")
+)
+
+// allowedTypes maps sniffed content types to the file extension used when
+// storing the blob.
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type Processed struct {
+	ContentType          string
+	Extension            string
+	Width                int
+	Height               int
+	Thumbnail            []byte
+	ThumbnailContentType string
+	ThumbnailExtension   string
+}
+
+// Process sniffs the content type of data, reads the image dimensions and
+// renders a thumbnail whose longest side is at most thumbnailMaxSide pixels.
+// The declared content type of the upload is never trusted. Images over
+// maxPixels are refused with ErrTooLarge without being decoded.
+func Process(data []byte, maxBytes int64) (Processed, error) {
+	if int64(len(data)) > maxBytes {
+		return Processed{}, ErrTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		return Processed{}, ErrUnsupportedType
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrUnsupportedType
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return Processed{}, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrUnsupportedType
+	}
+	thumb, thumbType, thumbExt, err := thumbnail(img, contentType)
+	if err != nil {
+		return Processed{}, err
+	}
+	return Processed{
+		ContentType:          contentType,
+		Extension:            ext,
+		Width:                cfg.Width,
+		Height:               cfg.Height,
+		Thumbnail:            thumb,
+		ThumbnailContentType: thumbType,
+		ThumbnailExtension:   thumbExt,
+	}, nil
+}
+
+func thumbnail(src image.Image, contentType string) ([]byte, string, string, error) {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > thumbnailMaxSide || h > thumbnailMaxSide {
+		if w >= h {
+			h = max(1, h*thumbnailMaxSide/w)
+			w = thumbnailMaxSide
+		} else {
+			w = max(1, w*thumbnailMaxSide/h)
+			h = thumbnailMaxSide
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+
+	var buf bytes.Buffer
+	// Photos stay JPEG; everything else becomes PNG to keep transparency.
+	if contentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "image/jpeg", ".jpg", nil
+	}
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "image/png", ".png", nil
+}
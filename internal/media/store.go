@@ -0,0 +1,22 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore persists uploaded media. Keys are slash separated paths such as
+// "chirps/<chirp id>/<media id>.png".
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Pinger is implemented by stores that can check they are reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
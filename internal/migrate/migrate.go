@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"io"
+	"io/fs"
+	"log/slog"
+	"text/tabwriter"
+)
+
+// ErrOutOfDate is returned by Check when the database is missing migrations
+// the binary knows about.
+var ErrOutOfDate = errors.New("database schema is out of date")
+
+// Migrator applies the goose migrations in a filesystem to a Postgres
+// database. Up and Down hold a Postgres advisory lock while they run so
+// instances started together take turns instead of racing.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// New returns a Migrator for the migrations in fsys, which usually comes
+// from the schema package.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, fsys,
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	results, err := m.provider.Up(ctx)
+	logResults(results)
+	return err
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	result, err := m.provider.Down(ctx)
+	if result != nil {
+		logResults([]*goose.MigrationResult{result})
+	}
+	return err
+}
+
+// Status writes one line per migration saying whether it has been applied.
+func (m *Migrator) Status(ctx context.Context, w io.Writer) error {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+	for _, s := range statuses {
+		applied := "-"
+		if s.State == goose.StateApplied {
+			applied = s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, applied, s.Source.Path)
+	}
+	return tw.Flush()
+}
+
+// Check returns ErrOutOfDate, wrapped with the current and expected versions,
+// if any migration has not been applied.
+func (m *Migrator) Check(ctx context.Context) error {
+	pending, err := m.provider.HasPending(ctx)
+	if err != nil {
+		return err
+	}
+	if !pending {
+		return nil
+	}
+	current, target, err := m.provider.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: at version %d, want %d", ErrOutOfDate, current, target)
+}
+
+func logResults(results []*goose.MigrationResult) {
+	for _, r := range results {
+		attrs := []any{"path", r.Source.Path, "version", r.Source.Version, "direction", r.Direction, "duration", r.Duration}
+		if r.Error != nil {
+			slog.Error("migration failed", append(attrs, "error", r.Error)...)
+			continue
+		}
+		slog.Info("migration applied", attrs...)
+	}
+}
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"database/sql"
+	"github.com/MattInReality/Chirpy/sql/schema"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+import _ "github.com/lib/pq"
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	// Opening does not connect, and loading the migrations only reads the
+	// embedded files.
+	db, err := sql.Open("postgres", "postgres://localhost/chirpy_test?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := New(db, schema.FS); err != nil {
+		t.Fatalf("expected the embedded migrations to load, got %v", err)
+	}
+}
+
+func TestEmbeddedMigrationsHaveDown(t *testing.T) {
+	names, err := fs.Glob(schema.FS, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("expected migrations to be embedded")
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(schema.FS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, marker := range []string{"-- +goose Up", "-- +goose Down"} {
+			if !strings.Contains(string(data), marker) {
+				t.Errorf("%s: missing %q", name, marker)
+			}
+		}
+	}
+}
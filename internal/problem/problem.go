@@ -0,0 +1,245 @@
+// Package problem describes API errors as RFC 7807 problem details and
+// decides which HTTP status each kind of domain error is answered with.
+package problem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ContentType is the media type of every error response.
+const ContentType = "application/problem+json"
+
+// Codes are stable identifiers clients can switch on. Unlike the detail
+// text, a code never changes once published.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeInvalidJSON          = "invalid_json"
+	CodeValidation           = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+	CodeUnavailable          = "service_unavailable"
+)
+
+// Domain errors. Wrap them to add context; From still recognises them.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// Details is the body of an error response. Code and Errors are extension
+// members; RequestID matches the X-Request-ID response header.
+type Details struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError explains what is wrong with one field of a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every field that failed validation so the client
+// can fix them all at once.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Add records a failed field.
+func (e *ValidationError) Add(field, code, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err returns e if any field failed and nil otherwise.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Invalid is shorthand for a ValidationError with a single field.
+func Invalid(field, code, message string) error {
+	return &ValidationError{Fields: []FieldError{{Field: field, Code: code, Message: message}}}
+}
+
+// Error is a failure that carries its own status, code and detail, for cases
+// where the generic code for the status says too little, such as an email
+// address that is already in use.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Err    error
+}
+
+// New returns an Error. err is the underlying cause and may be nil; it is
+// logged but never shown to the client.
+func New(status int, code, detail string, err error) *Error {
+	return &Error{Status: status, Code: code, Detail: detail, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// For returns the problem for status with the generic code for that status.
+func For(status int, detail string) Details {
+	return Details{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   CodeFor(status),
+	}
+}
+
+// CodeFor returns the generic code for a status.
+func CodeFor(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// From maps err to the problem the client is sent. It is the one place that
+// decides the status of a domain error. The text of unrecognised errors is
+// never exposed since it may describe our internals.
+func From(err error) Details {
+	var (
+		pe        *Error
+		invalid   *ValidationError
+		tooLarge  *http.MaxBytesError
+		syntax    *json.SyntaxError
+		wrongType *json.UnmarshalTypeError
+		sqlState  interface{ SQLState() string }
+	)
+	switch {
+	case errors.As(err, &pe):
+		d := For(pe.Status, pe.Detail)
+		if pe.Code != "" {
+			d.Code = pe.Code
+		}
+		return d
+	case errors.As(err, &invalid):
+		d := For(http.StatusBadRequest, "the request has invalid fields")
+		d.Code = CodeValidation
+		d.Errors = invalid.Fields
+		return d
+	case errors.As(err, &tooLarge):
+		return For(http.StatusRequestEntityTooLarge, "request body is too large")
+	case errors.Is(err, io.EOF):
+		d := For(http.StatusBadRequest, "request body is empty")
+		d.Code = CodeInvalidJSON
+		return d
+	case errors.As(err, &syntax), errors.Is(err, io.ErrUnexpectedEOF):
+		d := For(http.StatusBadRequest, "request body is not valid JSON")
+		d.Code = CodeInvalidJSON
+		return d
+	case errors.As(err, &wrongType):
+		d := For(http.StatusBadRequest, "request body has a field of the wrong type")
+		d.Code = CodeInvalidJSON
+		if wrongType.Field != "" {
+			d.Errors = []FieldError{{Field: wrongType.Field, Code: "type", Message: "must be " + jsonType(wrongType.Type)}}
+		}
+		return d
+	case errors.Is(err, ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return For(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	case errors.Is(err, ErrConflict), errors.As(err, &sqlState) && sqlState.SQLState() == "23505":
+		return For(http.StatusConflict, "the resource already exists")
+	case errors.Is(err, ErrUnauthorized):
+		return For(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+	case errors.Is(err, ErrForbidden):
+		return For(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+	}
+	return For(http.StatusInternalServerError, "something went wrong, please try again")
+}
+
+// jsonType describes a Go type the way a client writing JSON would.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a string"
+}
+
+// Write sends d as the response.
+func Write(w http.ResponseWriter, d Details) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(d.Status)
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,145 @@
+package problem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type pqError struct{ code string }
+
+func (e pqError) Error() string    { return "pq: " + e.code }
+func (e pqError) SQLState() string { return e.code }
+
+func decodeErr(body string) error {
+	var v struct {
+		Email string `json:"email"`
+	}
+	return json.NewDecoder(strings.NewReader(body)).Decode(&v)
+}
+
+func TestFromMapsDomainErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", ErrNotFound, http.StatusNotFound, CodeNotFound},
+		{"wrapped not found", fmt.Errorf("chirp 123: %w", ErrNotFound), http.StatusNotFound, CodeNotFound},
+		{"no rows", fmt.Errorf("get chirp: %w", sql.ErrNoRows), http.StatusNotFound, CodeNotFound},
+		{"conflict", ErrConflict, http.StatusConflict, CodeConflict},
+		{"unique violation", fmt.Errorf("create user: %w", pqError{"23505"}), http.StatusConflict, CodeConflict},
+		{"other database error", pqError{"40001"}, http.StatusInternalServerError, CodeInternal},
+		{"unauthorized", ErrUnauthorized, http.StatusUnauthorized, CodeUnauthorized},
+		{"forbidden", ErrForbidden, http.StatusForbidden, CodeForbidden},
+		{"validation", Invalid("body", "too_long", "must be at most 140 characters"), http.StatusBadRequest, CodeValidation},
+		{"empty body", decodeErr(""), http.StatusBadRequest, CodeInvalidJSON},
+		{"malformed json", decodeErr("{"), http.StatusBadRequest, CodeInvalidJSON},
+		{"bad syntax", decodeErr("{email}"), http.StatusBadRequest, CodeInvalidJSON},
+		{"wrong type", decodeErr(`{"email": 1}`), http.StatusBadRequest, CodeInvalidJSON},
+		{"body too large", &http.MaxBytesError{Limit: 10}, http.StatusRequestEntityTooLarge, CodePayloadTooLarge},
+		{"explicit", New(http.StatusConflict, "email_taken", "email is already in use", pqError{"23505"}), http.StatusConflict, "email_taken"},
+		{"unknown", errors.New("dial tcp: connection refused"), http.StatusInternalServerError, CodeInternal},
+		{"truncated body", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), http.StatusBadRequest, CodeInvalidJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := From(tt.err)
+			if d.Status != tt.status || d.Code != tt.code {
+				t.Fatalf("got %d %s, want %d %s", d.Status, d.Code, tt.status, tt.code)
+			}
+			if d.Title != http.StatusText(tt.status) {
+				t.Fatalf("title %q does not match status %d", d.Title, tt.status)
+			}
+		})
+	}
+}
+
+func TestFromHidesInternalErrors(t *testing.T) {
+	d := From(errors.New("pq: password authentication failed for user chirpy"))
+	if strings.Contains(d.Detail, "chirpy") {
+		t.Fatalf("internal error leaked into detail: %q", d.Detail)
+	}
+	d = From(New(http.StatusConflict, "email_taken", "email is already in use", errors.New("pq: duplicate key")))
+	if d.Detail != "email is already in use" {
+		t.Fatalf("unexpected detail %q", d.Detail)
+	}
+}
+
+func TestFromListsFieldErrors(t *testing.T) {
+	v := &ValidationError{}
+	if v.Err() != nil {
+		t.Fatal("empty validation error should be nil")
+	}
+	v.Add("email", "email", "must be a valid email address")
+	v.Add("password", "required", "is required")
+	d := From(fmt.Errorf("create user: %w", v.Err()))
+	if len(d.Errors) != 2 || d.Errors[0].Field != "email" || d.Errors[1].Code != "required" {
+		t.Fatalf("unexpected field errors %+v", d.Errors)
+	}
+
+	d = From(decodeErr(`{"email": true}`))
+	if len(d.Errors) != 1 || d.Errors[0].Field != "email" || d.Errors[0].Message != "must be a string" {
+		t.Fatalf("unexpected field errors %+v", d.Errors)
+	}
+}
+
+func TestCodeForStatus(t *testing.T) {
+	tests := map[int]string{
+		http.StatusBadRequest:          CodeBadRequest,
+		http.StatusNotFound:            CodeNotFound,
+		http.StatusTooManyRequests:     CodeRateLimited,
+		http.StatusServiceUnavailable:  CodeUnavailable,
+		http.StatusBadGateway:          CodeInternal,
+		http.StatusPreconditionFailed:  "precondition_failed",
+		http.StatusInternalServerError: CodeInternal,
+	}
+	for status, want := range tests {
+		if got := CodeFor(status); got != want {
+			t.Errorf("CodeFor(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	d := For(http.StatusNotFound, "chirp not found")
+	d.RequestID = "abc"
+	if err := Write(rec, d); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Fatalf("expected %s, got %s", ContentType, ct)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"type":       "about:blank",
+		"title":      "Not Found",
+		"status":     float64(404),
+		"detail":     "chirp not found",
+		"code":       "not_found",
+		"request_id": "abc",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected body %v", got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so that every
+// instance behind a load balancer shares the same limits.
+type PostgresStore struct {
+	conn *sql.DB
+	db   *database.Queries
+}
+
+func NewPostgresStore(conn *sql.DB, db *database.Queries) *PostgresStore {
+	return &PostgresStore{conn: conn, db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	// The column has no time zone, so always store UTC.
+	now = now.UTC()
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+	err = qtx.EnsureRateLimitBucket(ctx, database.EnsureRateLimitBucketParams{
+		Key:       key,
+		Tokens:    float64(limit.Requests),
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	b, err := qtx.GetRateLimitBucketForUpdate(ctx, key)
+	if err != nil {
+		return Result{}, err
+	}
+	tokens, res := take(limit, b.Tokens, b.UpdatedAt, now)
+	err = qtx.UpdateRateLimitBucket(ctx, database.UpdateRateLimitBucketParams{
+		Tokens:    tokens,
+		UpdatedAt: now,
+		Key:       key,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return res, tx.Commit()
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) error {
+	return s.db.DeleteStaleRateLimitBuckets(ctx, before.UTC())
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Limit is a token bucket that holds up to Requests tokens and refills
+// completely over Window. Each request takes one token.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Window.Seconds()
+}
+
+// Result describes the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed. It is
+	// zero when the request was allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps bucket state. Implementations must make Take atomic per key so
+// concurrent requests cannot spend the same token twice.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+	// Prune forgets buckets that have not been used since before.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// take refills a bucket that last held tokens at last, then tries to take one
+// token from it. It returns the tokens left and the result.
+func take(limit Limit, tokens float64, last, now time.Time) (float64, Result) {
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+	res := Result{Limit: limit.Requests}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	res.Remaining = int(tokens)
+	res.Reset = seconds((capacity - tokens) / rate)
+	return tokens, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// WriteHeaders sets the X-RateLimit-* headers for res, and Retry-After when
+// the request was refused. Durations are rounded up to whole seconds.
+func WriteHeaders(h http.Header, res Result) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// RunPruner prunes buckets idle for longer than maxIdle every interval until
+// ctx is cancelled.
+func RunPruner(ctx context.Context, s Store, interval, maxIdle time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := s.Prune(ctx, now.Add(-maxIdle)); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
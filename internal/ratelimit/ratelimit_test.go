@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Requests: 3, Window: 3 * time.Second}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		res, err := s.Take(ctx, "k", limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != i {
+			t.Fatalf("request %d: got allowed=%v remaining=%d", 3-i, res.Allowed, res.Remaining)
+		}
+	}
+	res, _ := s.Take(ctx, "k", limit, now)
+	if res.Allowed {
+		t.Fatal("expected the fourth request to be refused")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("retry after: got %v, want 1s", res.RetryAfter)
+	}
+
+	res, _ = s.Take(ctx, "other", limit, now)
+	if !res.Allowed {
+		t.Error("buckets should be independent per key")
+	}
+
+	res, _ = s.Take(ctx, "k", limit, now.Add(time.Second))
+	if !res.Allowed {
+		t.Error("expected one token to have been refilled after a second")
+	}
+	res, _ = s.Take(ctx, "k", limit, now.Add(time.Hour))
+	if !res.Allowed || res.Remaining != 2 {
+		t.Errorf("bucket should refill to capacity: got allowed=%v remaining=%d", res.Allowed, res.Remaining)
+	}
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Requests: 50, Window: time.Hour}
+	now := time.Now()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _ := s.Take(context.Background(), "k", limit, now)
+			if res.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 50 {
+		t.Errorf("allowed %d requests, want 50", allowed)
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Requests: 1, Window: time.Minute}
+	now := time.Now()
+	s.Take(context.Background(), "old", limit, now.Add(-time.Hour))
+	s.Take(context.Background(), "new", limit, now)
+	if err := s.Prune(context.Background(), now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.buckets["old"]; ok {
+		t.Error("expected idle bucket to be pruned")
+	}
+	if _, ok := s.buckets["new"]; !ok {
+		t.Error("expected recent bucket to be kept")
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	h := http.Header{}
+	WriteHeaders(h, Result{Allowed: false, Limit: 10, Remaining: 0, Reset: 5500 * time.Millisecond, RetryAfter: 200 * time.Millisecond})
+	want := map[string]string{
+		"X-RateLimit-Limit":     "10",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "6",
+		"Retry-After":           "1",
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("%s: got %q, want %q", k, got, v)
+		}
+	}
+
+	h = http.Header{}
+	WriteHeaders(h, Result{Allowed: true, Limit: 10, Remaining: 9})
+	if h.Get("Retry-After") != "" {
+		t.Error("Retry-After should only be set on refused requests")
+	}
+}
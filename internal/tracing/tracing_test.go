@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestQueryName(t *testing.T) {
+	testCases := []struct {
+		Query string
+		Want  string
+	}{
+		{"-- name: CreateChirp :one\nINSERT INTO chirps VALUES ($1)", "CreateChirp"},
+		{"-- name: DeleteWebhookEndpoint :execrows\nDELETE FROM webhook_endpoints", "DeleteWebhookEndpoint"},
+		{"SELECT 1", ""},
+		{"", ""},
+	}
+	for _, c := range testCases {
+		if got := QueryName(c.Query); got != c.Want {
+			t.Errorf("QueryName(%q) = %q, want %q", c.Query, got, c.Want)
+		}
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	if got := Traceparent(context.Background()); got != "" {
+		t.Fatalf("expected no traceparent outside a trace, got %q", got)
+	}
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := WithTraceparent(context.Background(), traceparent)
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsRemote() || sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected the remote span from the header, got %+v", sc)
+	}
+	if got := Traceparent(ctx); got != traceparent {
+		t.Fatalf("expected %q back, got %q", traceparent, got)
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), "zipkin", nil); err == nil {
+		t.Fatal("expected an unknown exporter to be rejected")
+	}
+}
@@ -0,0 +1,230 @@
+// Package validate checks request bodies against rules declared in struct
+// tags:
+//
+//	type params struct {
+//		Email string   `json:"email" validate:"required,email"`
+//		Bio   string   `json:"bio" validate:"max=160"`
+//		Tags  []string `json:"tags" validate:"max=5,handle"`
+//	}
+//
+// The built-in rules are required, email, uuid, url (absolute http or https),
+// min=N and max=N (length in characters), and oneof=a b c. Rules added with
+// Register work the same way.
+//
+// A field that is empty and not required is not checked further. A pointer
+// field is empty only when nil, so an explicit "" is still checked. On a
+// slice, required, min and max apply to the number of items and every other
+// rule to each item.
+package validate
+
+import (
+	"fmt"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"github.com/google/uuid"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Func reports whether a string satisfies a rule.
+type Func func(string) bool
+
+type custom struct {
+	message string
+	fn      Func
+}
+
+var (
+	mu    sync.RWMutex
+	rules = map[string]custom{}
+)
+
+var builtin = map[string]bool{
+	"required": true,
+	"email":    true,
+	"uuid":     true,
+	"url":      true,
+	"min":      true,
+	"max":      true,
+	"oneof":    true,
+}
+
+// Register adds a rule for string fields. A field that fails it is reported
+// with the rule's name as its code and message as its message. Registering a
+// name twice panics.
+func Register(name, message string, fn Func) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := rules[name]; ok || builtin[name] {
+		panic("validate: rule " + name + " registered twice")
+	}
+	rules[name] = custom{message: message, fn: fn}
+}
+
+type rule struct {
+	name string
+	arg  string
+}
+
+// Struct checks every field of the struct v points to and returns a
+// *problem.ValidationError listing each one that failed, or nil. A malformed
+// tag is a programming error and panics.
+func Struct(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: Struct needs a struct, got %s", rv.Kind()))
+	}
+	errs := &problem.ValidationError{}
+	t := rv.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("validate")
+		if !ok || !f.IsExported() {
+			continue
+		}
+		checkField(errs, fieldName(f), rv.Field(i), parse(tag))
+	}
+	return errs.Err()
+}
+
+// fieldName is the name the client used: the JSON key if there is one.
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+func parse(tag string) []rule {
+	var rs []rule
+	for _, part := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if !builtin[name] {
+			mu.RLock()
+			_, ok := rules[name]
+			mu.RUnlock()
+			if !ok {
+				panic("validate: unknown rule " + name)
+			}
+		}
+		rs = append(rs, rule{name: name, arg: arg})
+	}
+	return rs
+}
+
+func checkField(errs *problem.ValidationError, name string, v reflect.Value, rs []rule) {
+	present := true
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			present = false
+		} else {
+			v = v.Elem()
+		}
+	case reflect.String, reflect.Slice:
+		present = v.Len() > 0
+	default:
+		present = !v.IsZero()
+	}
+	if !present {
+		if hasRule(rs, "required") {
+			errs.Add(name, "required", "is required")
+		}
+		return
+	}
+
+	if v.Kind() == reflect.Slice {
+		var itemRules []rule
+		for _, r := range rs {
+			switch r.name {
+			case "required":
+			case "min", "max":
+				if ok, code, msg := checkLength(v.Len(), r, "items"); !ok {
+					errs.Add(name, code, msg)
+					return
+				}
+			default:
+				itemRules = append(itemRules, r)
+			}
+		}
+		for i := range v.Len() {
+			checkString(errs, fmt.Sprintf("%s[%d]", name, i), v.Index(i), itemRules)
+		}
+		return
+	}
+	checkString(errs, name, v, rs)
+}
+
+func checkString(errs *problem.ValidationError, name string, v reflect.Value, rs []rule) {
+	if v.Kind() != reflect.String {
+		panic(fmt.Sprintf("validate: %s is a %s, rules only apply to strings", name, v.Kind()))
+	}
+	s := v.String()
+	for _, r := range rs {
+		if r.name == "required" {
+			continue
+		}
+		if ok, code, msg := check(s, r); !ok {
+			errs.Add(name, code, msg)
+			return
+		}
+	}
+}
+
+func check(s string, r rule) (ok bool, code, message string) {
+	switch r.name {
+	case "email":
+		// ParseAddress also accepts a display name and angle brackets; only
+		// the bare address is allowed.
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s, "email", "must be a valid email address"
+	case "uuid":
+		_, err := uuid.Parse(s)
+		return err == nil, "uuid", "must be a UUID"
+	case "url":
+		u, err := url.Parse(s)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be an absolute http or https URL"
+	case "min", "max":
+		return checkLength(utf8.RuneCountInString(s), r, "characters")
+	case "oneof":
+		choices := strings.Fields(r.arg)
+		for _, c := range choices {
+			if s == c {
+				return true, "", ""
+			}
+		}
+		return false, "invalid_choice", "must be one of " + strings.Join(choices, ", ")
+	}
+	mu.RLock()
+	c := rules[r.name]
+	mu.RUnlock()
+	return c.fn(s), r.name, c.message
+}
+
+func checkLength(n int, r rule, unit string) (ok bool, code, message string) {
+	limit, err := strconv.Atoi(r.arg)
+	if err != nil {
+		panic("validate: " + r.name + " needs a number, got " + r.arg)
+	}
+	if limit == 1 {
+		unit = strings.TrimSuffix(unit, "s")
+	}
+	if r.name == "min" {
+		return n >= limit, "too_short", fmt.Sprintf("must be at least %d %s", limit, unit)
+	}
+	return n <= limit, "too_long", fmt.Sprintf("must be at most %d %s", limit, unit)
+}
+
+func hasRule(rs []rule, name string) bool {
+	for _, r := range rs {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package validate
+
+import (
+	"errors"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	Register("lower", "must be lower case", func(s string) bool { return s == strings.ToLower(s) })
+}
+
+type signup struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Password string   `json:"password" validate:"required,min=8"`
+	Bio      string   `json:"bio" validate:"max=5"`
+	Referrer string   `json:"referrer" validate:"uuid"`
+	Website  string   `json:"website" validate:"url"`
+	Plan     string   `json:"plan" validate:"oneof=free red"`
+	Tags     []string `json:"tags" validate:"max=2,lower"`
+	Nickname *string  `json:"nickname" validate:"required,min=1"`
+	Ignored  string   `json:"ignored"`
+}
+
+func fields(t *testing.T, err error) []problem.FieldError {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var v *problem.ValidationError
+	if !errors.As(err, &v) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	return v.Fields
+}
+
+func TestValidStruct(t *testing.T) {
+	nick := "mo"
+	s := signup{
+		Email:    "mo@example.com",
+		Password: "hunter22",
+		Bio:      "héllo",
+		Referrer: "0b7b2a8e-5f0c-4c34-9a8c-2d0e1f3a4b5c",
+		Website:  "https://example.com",
+		Plan:     "red",
+		Tags:     []string{"go", "sql"},
+		Nickname: &nick,
+	}
+	if err := Struct(&s); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestOptionalFieldsMayBeEmpty(t *testing.T) {
+	nick := "mo"
+	s := signup{Email: "mo@example.com", Password: "hunter22", Nickname: &nick}
+	if err := Struct(s); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestEveryFailureIsReported(t *testing.T) {
+	empty := ""
+	s := signup{
+		Email:    "not an email",
+		Bio:      "too long",
+		Referrer: "123",
+		Website:  "ftp://example.com",
+		Plan:     "gold",
+		Tags:     []string{"ok", "NOPE"},
+		Nickname: &empty,
+	}
+	got := fields(t, Struct(&s))
+	want := []problem.FieldError{
+		{Field: "email", Code: "email", Message: "must be a valid email address"},
+		{Field: "password", Code: "required", Message: "is required"},
+		{Field: "bio", Code: "too_long", Message: "must be at most 5 characters"},
+		{Field: "referrer", Code: "uuid", Message: "must be a UUID"},
+		{Field: "website", Code: "url", Message: "must be an absolute http or https URL"},
+		{Field: "plan", Code: "invalid_choice", Message: "must be one of free, red"},
+		{Field: "tags[1]", Code: "lower", Message: "must be lower case"},
+		{Field: "nickname", Code: "too_short", Message: "must be at least 1 character"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestEmailMustBeABareAddress(t *testing.T) {
+	type form struct {
+		Email string `json:"email" validate:"email"`
+	}
+	tests := []struct {
+		email string
+		ok    bool
+	}{
+		{"bob@x.com", true},
+		{"Bob <bob@x.com>", false},
+		{"<bob@x.com>", false},
+		{" bob@x.com", false},
+		{"bob", false},
+	}
+	for _, tt := range tests {
+		err := Struct(form{Email: tt.email})
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: expected ok=%v, got %v", tt.email, tt.ok, err)
+		}
+	}
+}
+
+func TestSliceLengthAndRequiredPointer(t *testing.T) {
+	s := signup{Email: "mo@example.com", Password: "hunter22", Tags: []string{"a", "b", "c"}}
+	got := fields(t, Struct(&s))
+	if len(got) != 2 || got[0].Field != "tags" || got[0].Code != "too_long" || got[1].Field != "nickname" || got[1].Code != "required" {
+		t.Fatalf("unexpected fields %+v", got)
+	}
+}
+
+func TestBadTagsPanic(t *testing.T) {
+	tests := map[string]any{
+		"unknown rule": &struct {
+			A string `validate:"shiny"`
+		}{A: "x"},
+		"bad length": &struct {
+			A string `validate:"max=ten"`
+		}{A: "x"},
+		"not a string": &struct {
+			A int `validate:"email"`
+		}{A: 1},
+		"not a struct": new(string),
+	}
+	for name, v := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected a panic")
+				}
+			}()
+			Struct(v)
+		})
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	Register("email", "", func(string) bool { return true })
+}
@@ -0,0 +1,104 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateDestination is returned for a webhook that would be sent to this
+// machine or a private network. Users choose webhook URLs, so without this
+// check they could use deliveries to probe internal services.
+var ErrPrivateDestination = errors.New("webhooks: destination is a local or private address")
+
+// blocked are ranges that are not private by net/netip's definition but
+// still do not belong to the public internet.
+var blocked = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// AllowedAddr reports whether deliveries may be sent to addr. Loopback,
+// link-local (which includes cloud metadata services), private, multicast
+// and unspecified addresses are refused.
+func AllowedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range blocked {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL returns ErrPrivateDestination if the host of rawURL is, or
+// resolves to, an address AllowedAddr refuses. A host that does not resolve
+// is accepted: it cannot be delivered to yet, and Control checks it again
+// when it does.
+func CheckURL(ctx context.Context, resolver *net.Resolver, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !AllowedAddr(addr) {
+			return ErrPrivateDestination
+		}
+		return nil
+	}
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !AllowedAddr(addr) {
+			return ErrPrivateDestination
+		}
+	}
+	return nil
+}
+
+// Control is a net.Dialer Control function that refuses to connect to an
+// address AllowedAddr refuses. It runs after DNS resolution, so a host that
+// is repointed at an internal address after it was registered is still
+// caught.
+func Control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !AllowedAddr(addrPort.Addr()) {
+		return ErrPrivateDestination
+	}
+	return nil
+}
+
+// NewTransport returns the transport deliveries are sent with. Unless
+// allowPrivate is set, it only connects to public addresses. It ignores proxy
+// settings, since a proxy would make the connection on its behalf and skip
+// the check.
+func NewTransport(allowPrivate bool) http.RoundTripper {
+	if allowPrivate {
+		return http.DefaultTransport
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   Control,
+	}).DialContext
+	return t
+}
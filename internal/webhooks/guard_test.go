@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestAllowedAddr(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"224.0.0.1":       false,
+		"::ffff:10.0.0.1": false,
+	}
+	for addr, want := range tests {
+		if got := AllowedAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("AllowedAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := map[string]error{
+		"https://93.184.216.34/hook":         nil,
+		"http://127.0.0.1:8080/hook":         ErrPrivateDestination,
+		"http://169.254.169.254/latest/meta": ErrPrivateDestination,
+		"http://[::1]/hook":                  ErrPrivateDestination,
+		"http://192.168.0.10/hook":           ErrPrivateDestination,
+		"http://localhost:9000/hook":         ErrPrivateDestination,
+		"https://does-not-resolve.invalid/x": nil,
+	}
+	for raw, want := range tests {
+		if err := CheckURL(context.Background(), net.DefaultResolver, raw); !errors.Is(err, want) {
+			t.Errorf("CheckURL(%s) = %v, want %v", raw, err, want)
+		}
+	}
+}
+
+func TestTransportRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(false)}
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, ErrPrivateDestination) {
+		t.Fatalf("expected the dial to be refused, got %v", err)
+	}
+
+	client = &http.Client{Transport: NewTransport(true)}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the dial to be allowed, got %v", err)
+	}
+	resp.Body.Close()
+}
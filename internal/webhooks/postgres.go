@@ -0,0 +1,90 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"time"
+)
+
+// PostgresStore keeps the queue in the webhook_deliveries table. Claimed
+// deliveries are skipped by other instances until their lease has passed,
+// after which a delivery whose worker died is picked up again.
+type PostgresStore struct {
+	conn *sql.DB
+	db   *database.Queries
+}
+
+func NewPostgresStore(conn *sql.DB, db *database.Queries) *PostgresStore {
+	return &PostgresStore{conn: conn, db: db}
+}
+
+func (s *PostgresStore) Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Delivery, error) {
+	// The columns have no time zone, so always store UTC.
+	now = now.UTC()
+	rows, err := s.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: now.Add(lease),
+		Now:        now,
+		RowLimit:   int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, 0, len(rows))
+	for _, row := range rows {
+		endpoint, err := s.db.GetWebhookEndpointByID(ctx, row.EndpointID)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, Delivery{
+			ID:          row.ID,
+			URL:         endpoint.Url,
+			Secret:      endpoint.Secret,
+			Event:       row.Event,
+			Payload:     row.Payload,
+			Attempts:    int(row.Attempts),
+			Traceparent: row.Traceparent,
+		})
+	}
+	return deliveries, nil
+}
+
+func (s *PostgresStore) Record(ctx context.Context, d Delivery, a Attempt, status string, next time.Time) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+	errText := ""
+	if a.Err != nil {
+		errText = a.Err.Error()
+	}
+	err = qtx.RecordWebhookDeliveryAttempt(ctx, database.RecordWebhookDeliveryAttemptParams{
+		ID:          uuid.New(),
+		DeliveryID:  d.ID,
+		AttemptedAt: a.At.UTC(),
+		StatusCode:  int32(a.StatusCode),
+		Error:       errText,
+		DurationMs:  int32(a.Duration.Milliseconds()),
+	})
+	if err != nil {
+		return err
+	}
+	delivered := sql.NullTime{}
+	if status == StatusDelivered {
+		delivered = sql.NullTime{Time: a.At.UTC(), Valid: true}
+	}
+	err = qtx.UpdateWebhookDelivery(ctx, database.UpdateWebhookDeliveryParams{
+		Status:        status,
+		NextAttemptAt: next.UTC(),
+		LastError:     errText,
+		DeliveredAt:   delivered,
+		ID:            d.ID,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
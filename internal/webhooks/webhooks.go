@@ -0,0 +1,231 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Delivery statuses. A pending delivery is retried until it succeeds or runs
+// out of attempts, at which point it is dead and left for someone to retry by
+// hand.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+const (
+	DefaultMaxAttempts = 8
+	maxResponseBytes   = 64 << 10
+	// defaultSendTimeout bounds a send when the client has no timeout.
+	defaultSendTimeout = 30 * time.Second
+	// leaseMargin is added to a batch's worst case send time to cover
+	// claiming it and recording the results.
+	leaseMargin = time.Minute
+)
+
+// Delivery is one event on its way to one endpoint.
+type Delivery struct {
+	ID      uuid.UUID
+	URL     string
+	Secret  string
+	Event   string
+	Payload []byte
+	// Attempts is how many attempts have already been made.
+	Attempts int
+	// Traceparent is the trace context of the request that queued the
+	// delivery, so sending it shows up in the same trace.
+	Traceparent string
+}
+
+// Attempt is the outcome of one try at sending a delivery.
+type Attempt struct {
+	At         time.Time
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
+// OK reports whether the receiver accepted the delivery with a 2xx response.
+func (a Attempt) OK() bool {
+	return a.Err == nil && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// Store is the delivery queue.
+type Store interface {
+	// Claim returns up to limit deliveries that are due and leases them until
+	// now+lease so other workers leave them alone while they are being sent.
+	Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Delivery, error)
+	// Record saves an attempt along with the delivery's new status and, for
+	// pending deliveries, when to try again.
+	Record(ctx context.Context, d Delivery, a Attempt, status string, next time.Time) error
+}
+
+// Envelope is the JSON body every delivery carries.
+type Envelope struct {
+	ID        uuid.UUID `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Backoff returns how long to wait before the attempt after attempt number n:
+// 30 seconds after the first, doubling each time up to 12 hours.
+func Backoff(n int) time.Duration {
+	const maxBackoff = 12 * time.Hour
+	d := 30 * time.Second
+	for i := 1; i < n; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// Send posts a delivery to its endpoint. The body is signed with the
+// endpoint's secret in the Chirpy-Signature header, in the same format as
+// auth.SignPayload.
+func Send(ctx context.Context, client *http.Client, d Delivery, now time.Time) Attempt {
+	a := Attempt{At: now}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		a.Err = err
+		return a
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1")
+	req.Header.Set("Chirpy-Event", d.Event)
+	req.Header.Set("Chirpy-Delivery-Id", d.ID.String())
+	req.Header.Set("Chirpy-Signature", auth.SignPayload(d.Secret, d.Payload, now))
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		a.Err = err
+		a.Duration = time.Since(start)
+		return a
+	}
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBytes))
+	res.Body.Close()
+	a.Duration = time.Since(start)
+	a.StatusCode = res.StatusCode
+	if !a.OK() {
+		a.Err = fmt.Errorf("endpoint responded with %s", res.Status)
+	}
+	return a
+}
+
+// Worker sends due deliveries from a Store.
+type Worker struct {
+	Store       Store
+	Client      *http.Client
+	MaxAttempts int
+	BatchSize   int
+}
+
+func (w *Worker) batchSize() int {
+	if w.BatchSize <= 0 {
+		return 20
+	}
+	return w.BatchSize
+}
+
+// sendTimeout is the longest a single send may take.
+func (w *Worker) sendTimeout() time.Duration {
+	if w.Client.Timeout > 0 {
+		return w.Client.Timeout
+	}
+	return defaultSendTimeout
+}
+
+// Lease is how long a claimed batch is kept from other workers. Deliveries
+// are sent one after another, so it covers every send in a full batch timing
+// out; a shorter lease would let another worker claim and send deliveries
+// that are still on their way.
+func (w *Worker) Lease() time.Duration {
+	return time.Duration(w.batchSize())*w.sendTimeout() + leaseMargin
+}
+
+// RunOnce sends one batch of due deliveries and returns how many it sent.
+func (w *Worker) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	maxAttempts := w.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	deliveries, err := w.Store.Claim(ctx, now, w.batchSize(), w.Lease())
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range deliveries {
+		if err := w.deliver(ctx, d, now, maxAttempts); err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+func (w *Worker) deliver(ctx context.Context, d Delivery, now time.Time, maxAttempts int) error {
+	ctx, span := otel.Tracer("github.com/MattInReality/Chirpy/internal/webhooks").Start(
+		tracing.WithTraceparent(ctx, d.Traceparent), "webhooks.deliver",
+		trace.WithAttributes(
+			attribute.String("webhook.event", d.Event),
+			attribute.String("webhook.delivery_id", d.ID.String()),
+			attribute.Int("webhook.attempt", d.Attempts+1),
+		),
+	)
+	defer span.End()
+	sendCtx, cancel := context.WithTimeout(ctx, w.sendTimeout())
+	a := Send(sendCtx, w.Client, d, now)
+	cancel()
+	attempts := d.Attempts + 1
+	status, next := StatusPending, now.Add(Backoff(attempts))
+	switch {
+	case a.OK():
+		status = StatusDelivered
+	case attempts >= maxAttempts:
+		status = StatusDead
+	}
+	span.SetAttributes(attribute.String("webhook.status", status))
+	if a.Err != nil {
+		span.SetStatus(codes.Error, a.Err.Error())
+	}
+	return w.Store.Record(ctx, d, a, status, next)
+}
+
+// Run polls for due deliveries every interval until ctx is done. A full batch
+// is followed straight away by another, so a backlog drains without waiting.
+func (w *Worker) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := w.RunOnce(ctx, time.Now())
+		if err != nil && onError != nil {
+			onError(err)
+		}
+		if err == nil && n == w.batchSize() {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// NewPayload encodes an event envelope.
+func NewPayload(id uuid.UUID, event string, at time.Time, data any) ([]byte, error) {
+	return json.Marshal(Envelope{ID: id, Event: event, CreatedAt: at, Data: data})
+}
@@ -0,0 +1,277 @@
+package webhooks
+
+import (
+	"context"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queue is an in memory Store that hands out every pending delivery that is
+// due and leases it, like the Postgres store does.
+type queue struct {
+	mu         sync.Mutex
+	deliveries map[uuid.UUID]*queued
+}
+
+type queued struct {
+	Delivery
+	status   string
+	next     time.Time
+	attempts []Attempt
+}
+
+func newQueue(ds ...Delivery) *queue {
+	q := &queue{deliveries: map[uuid.UUID]*queued{}}
+	for _, d := range ds {
+		q.deliveries[d.ID] = &queued{Delivery: d, status: StatusPending}
+	}
+	return q
+}
+
+func (q *queue) Claim(_ context.Context, now time.Time, limit int, lease time.Duration) ([]Delivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []Delivery
+	for _, d := range q.deliveries {
+		if d.status == StatusPending && !d.next.After(now) && len(out) < limit {
+			d.next = now.Add(lease)
+			out = append(out, d.Delivery)
+		}
+	}
+	return out, nil
+}
+
+func (q *queue) Record(_ context.Context, d Delivery, a Attempt, status string, next time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stored := q.deliveries[d.ID]
+	stored.Attempts++
+	stored.status = status
+	stored.next = next
+	stored.attempts = append(stored.attempts, a)
+	return nil
+}
+
+func TestWorkerDeliversSignedPayload(t *testing.T) {
+	const secret = "whsec_test"
+	var got struct {
+		body      []byte
+		event     string
+		signature string
+	}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.body, _ = io.ReadAll(r.Body)
+		got.event = r.Header.Get("Chirpy-Event")
+		got.signature = r.Header.Get("Chirpy-Signature")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	now := time.Now()
+	payload, err := NewPayload(uuid.New(), "chirp.created", now, map[string]string{"body": "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Delivery{ID: uuid.New(), URL: receiver.URL, Secret: secret, Event: "chirp.created", Payload: payload}
+	q := newQueue(d)
+	w := &Worker{Store: q, Client: receiver.Client()}
+	n, err := w.RunOnce(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 delivery to be sent, got %d", n)
+	}
+	if got.event != "chirp.created" || string(got.body) != string(payload) {
+		t.Fatalf("receiver got event %q body %s", got.event, got.body)
+	}
+	if err := auth.VerifySignature(got.signature, secret, got.body, time.Minute, now); err != nil {
+		t.Fatalf("signature did not verify: %v", err)
+	}
+	stored := q.deliveries[d.ID]
+	if stored.status != StatusDelivered || stored.attempts[0].StatusCode != http.StatusNoContent {
+		t.Fatalf("expected delivered with 204, got %s with %d", stored.status, stored.attempts[0].StatusCode)
+	}
+}
+
+func TestWorkerRetriesThenDeadLetters(t *testing.T) {
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	d := Delivery{ID: uuid.New(), URL: receiver.URL, Secret: "s", Event: "user.created", Payload: []byte(`{}`)}
+	q := newQueue(d)
+	w := &Worker{Store: q, Client: receiver.Client(), MaxAttempts: 3}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	if _, err := w.RunOnce(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	stored := q.deliveries[d.ID]
+	if stored.status != StatusPending || !stored.next.Equal(now.Add(30*time.Second)) {
+		t.Fatalf("expected a retry in 30s, got %s at %v", stored.status, stored.next)
+	}
+	if stored.attempts[0].Err == nil {
+		t.Fatal("expected the failed attempt to carry an error")
+	}
+
+	// Not due yet, so nothing is sent.
+	if n, _ := w.RunOnce(ctx, now.Add(10*time.Second)); n != 0 {
+		t.Fatalf("expected nothing to be due, sent %d", n)
+	}
+
+	w.RunOnce(ctx, stored.next)
+	if !stored.next.Equal(now.Add(30*time.Second + time.Minute)) {
+		t.Fatalf("expected the second retry to back off to 1m, got %v", stored.next.Sub(now))
+	}
+	w.RunOnce(ctx, stored.next)
+	if stored.status != StatusDead {
+		t.Fatalf("expected dead after 3 attempts, got %s", stored.status)
+	}
+	if calls != 3 || len(stored.attempts) != 3 {
+		t.Fatalf("expected 3 attempts, receiver saw %d and %d were recorded", calls, len(stored.attempts))
+	}
+	if n, _ := w.RunOnce(ctx, now.Add(24*time.Hour)); n != 0 {
+		t.Fatal("expected dead deliveries to stay put")
+	}
+}
+
+func TestWorkerLeaseOutlastsBatch(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		hold := r.URL.Path == "/first" && hits["/first"] == 1
+		mu.Unlock()
+		// Hold the first delivery open while the other worker polls.
+		if hold {
+			close(arrived)
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	defer unblock()
+
+	first := Delivery{ID: uuid.New(), URL: receiver.URL + "/first", Secret: "s", Event: "user.created", Payload: []byte(`{}`)}
+	second := Delivery{ID: uuid.New(), URL: receiver.URL + "/second", Secret: "s", Event: "user.created", Payload: []byte(`{}`)}
+	q := newQueue(first, second)
+	// Two sends of up to 45s each outlast a fixed one minute lease.
+	client := &http.Client{Timeout: 45 * time.Second}
+	a := &Worker{Store: q, Client: client, BatchSize: 2}
+	b := &Worker{Store: q, Client: client, BatchSize: 2}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	done := make(chan error)
+	go func() {
+		_, err := a.RunOnce(ctx, now)
+		done <- err
+	}()
+	<-arrived
+
+	// Both sends in the batch could take the full client timeout, so another
+	// worker polling that much later must still find them leased.
+	late := now.Add(time.Duration(a.BatchSize) * client.Timeout)
+	if n, err := b.RunOnce(ctx, late); err != nil || n != 0 {
+		t.Fatalf("expected the batch to still be leased, sent %d (%v)", n, err)
+	}
+	unblock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if hits["/first"] != 1 || hits["/second"] != 1 {
+		t.Fatalf("expected each delivery to be sent once, got %v", hits)
+	}
+}
+
+func TestWorkerUnreachableEndpoint(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	url := receiver.URL
+	receiver.Close()
+
+	d := Delivery{ID: uuid.New(), URL: url, Secret: "s", Event: "user.created", Payload: []byte(`{}`)}
+	q := newQueue(d)
+	w := &Worker{Store: q, Client: &http.Client{Timeout: time.Second}}
+	if _, err := w.RunOnce(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	stored := q.deliveries[d.ID]
+	if stored.status != StatusPending || stored.attempts[0].Err == nil || stored.attempts[0].StatusCode != 0 {
+		t.Fatalf("expected a failed attempt without a status code, got %+v", stored.attempts[0])
+	}
+}
+
+func TestWorkerPropagatesTraceContext(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	var traceparent string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	const origin = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	d := Delivery{ID: uuid.New(), URL: receiver.URL, Secret: "s", Event: "user.created", Payload: []byte(`{}`), Traceparent: origin}
+	client := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	w := &Worker{Store: newQueue(d), Client: client}
+	if _, err := w.RunOnce(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(traceparent, "4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Fatalf("expected the receiver to see the queuing request's trace, got %q", traceparent)
+	}
+	var found bool
+	for _, s := range spans.GetSpans() {
+		if s.Name == "webhooks.deliver" {
+			found = s.Parent.SpanID().String() == "00f067aa0ba902b7"
+		}
+	}
+	if !found {
+		t.Fatal("expected a webhooks.deliver span under the queuing request's span")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	testCases := []struct {
+		Attempt int
+		Want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{20, 12 * time.Hour},
+	}
+	for _, c := range testCases {
+		if got := Backoff(c.Attempt); got != c.Want {
+			t.Errorf("Backoff(%d) = %v, want %v", c.Attempt, got, c.Want)
+		}
+	}
+}
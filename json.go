@@ -2,33 +2,55 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"log/slog"
 	"net/http"
 )
 
+// respondWithError writes an application/problem+json error with the generic
+// code for its status. message becomes the problem's detail.
 func respondWithError(w http.ResponseWriter, code int, message string, err error) {
+	writeProblem(w, problem.For(code, message), err)
+}
+
+// respondWithProblem writes the problem that err maps to. Handlers use it for
+// domain errors, such as a missing row or a field that failed validation, so
+// the status is decided in one place rather than at every call site.
+func respondWithProblem(w http.ResponseWriter, err error) {
+	writeProblem(w, problem.From(err), err)
+}
+
+// writeProblem sends p, tagged with the request ID. Server errors are logged
+// at error level; client errors only at debug level since they are expected
+// traffic.
+func writeProblem(w http.ResponseWriter, p problem.Details, err error) {
+	ctx := contextOf(w)
+	level := slog.LevelDebug
+	if p.Status > 499 {
+		level = slog.LevelError
+	}
+	attrs := []any{"status", p.Status, "code", p.Code, "message", p.Detail}
 	if err != nil {
-		log.Println(err)
+		attrs = append(attrs, "error", err)
 	}
-
-	if code > 499 {
-		log.Printf("responding with 5XX error: %s\n", message)
+	if err != nil || level == slog.LevelError {
+		logger(ctx).Log(ctx, level, "responding with error", attrs...)
 	}
-
-	type responseError struct {
-		Error string `json:"error"`
+	p.RequestID = requestID(ctx)
+	if err := problem.Write(w, p); err != nil {
+		logger(ctx).ErrorContext(ctx, "could not write error response", "error", err)
 	}
-
-	respondWithJson(w, code, responseError{Error: message})
 }
 
 func respondWithJson(w http.ResponseWriter, code int, body interface{}) {
 	data, err := json.Marshal(body)
 	if err != nil {
-		log.Printf("Error marshalling json: %s\n", err)
+		ctx := contextOf(w)
+		logger(ctx).ErrorContext(ctx, "could not marshal response", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	w.Write(data)
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"github.com/MattInReality/Chirpy/internal/config"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients so they
+// cannot stuff the logs.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// newLogger builds the logger described by the log_level and log_format
+// settings.
+func newLogger(conf *config.Config, w io.Writer) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(conf.LogLevel))
+	opts := &slog.HandlerOptions{Level: level}
+	if conf.LogFormat == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// fatal logs a startup failure and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// requestID returns the ID middlewareRequestID gave the request, if any.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logger returns the default logger, tagged with the request ID when ctx
+// belongs to a request and with the trace ID when it is being traced.
+func logger(ctx context.Context) *slog.Logger {
+	l := slog.Default()
+	if id := requestID(ctx); id != "" {
+		l = l.With("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		l = l.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	}
+	return l
+}
+
+// middlewareRequestID keeps the X-Request-ID sent by the client, or a proxy in
+// front of us, and makes one up otherwise. The ID is echoed in the response
+// and attached to every log line written for the request.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder remembers what a handler wrote for the access log, and
+// carries the request context so respondWithError can log against it.
+type responseRecorder struct {
+	http.ResponseWriter
+	ctx    context.Context
+	status int
+	bytes  int64
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *responseRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// contextOf returns the context of the request w is answering, or the
+// background context outside middlewareAccessLog.
+func contextOf(w http.ResponseWriter) context.Context {
+	if rec, ok := w.(*responseRecorder); ok {
+		return rec.ctx
+	}
+	return context.Background()
+}
+
+// middlewareAccessLog writes one log line per request once it has been
+// served. The route is the mux pattern rather than the path so chirp and user
+// IDs do not explode the number of distinct values.
+func (cfg *apiConfig) middlewareAccessLog(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, ctx: r.Context()}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		_, route := mux.Handler(r)
+		attrs := []any{
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int64("bytes", rec.bytes),
+		}
+		if viewer := cfg.viewerID(r); viewer.Valid {
+			attrs = append(attrs, slog.String("user_id", viewer.UUID.String()))
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		logger(r.Context()).Log(r.Context(), level, "request", attrs...)
+	})
+}
@@ -1,81 +1,190 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/config"
 	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/entitlements"
+	"github.com/MattInReality/Chirpy/internal/filter"
+	"github.com/MattInReality/Chirpy/internal/health"
+	"github.com/MattInReality/Chirpy/internal/media"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"github.com/MattInReality/Chirpy/internal/ratelimit"
+	"github.com/MattInReality/Chirpy/internal/tracing"
+	"github.com/MattInReality/Chirpy/internal/webhooks"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"io"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"log"
+	"log/slog"
 	"net/http"
-	"net/mail"
 	"os"
+	"os/signal"
 	"sort"
+	"strconv"
 	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 )
 
 import _ "github.com/lib/pq"
 
 func main() {
-	const filepathRoot = "."
-	const port = "8080"
-	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	db, err := sql.Open("postgres", dbURL)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	conf, err := config.Load(config.Options{
+		Args:      os.Args[1:],
+		LookupEnv: os.LookupEnv,
+		DotEnv:    ".env",
+	})
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	slog.SetDefault(newLogger(conf, os.Stderr))
+	shutdownTracing, err := tracing.Setup(context.Background(), conf.TracingExporter, os.Stdout)
+	if err != nil {
+		fatal("could not set up tracing", err)
+	}
+	db, err := tracing.OpenDB("postgres", conf.DBURL)
+	if err != nil {
+		fatal("could not connect to db", err)
+	}
+	migrator, err := prepareSchema(context.Background(), conf, db)
 	if err != nil {
-		log.Fatal("could not connect to db")
+		fatal("database schema is not ready", err)
 	}
 	queries := database.New(db)
+	blobStore, err := newBlobStore(conf)
+	if err != nil {
+		fatal("could not set up media storage", err)
+	}
+	profanity, err := newProfanityFilter(conf)
+	if err != nil {
+		fatal("could not load profanity filter", err)
+	}
+	limiter := newRateLimitStore(conf, db, queries)
+	tiers, err := newEntitlements(conf)
+	if err != nil {
+		fatal("could not load entitlements", err)
+	}
 
 	apiCfg := &apiConfig{
-		db:       queries,
-		platform: os.Getenv("PLATFORM"),
-		secret:   os.Getenv("JWT_SECRET"),
-		apiKey:   os.Getenv("POLKA_KEY"),
+		db:                  queries,
+		conn:                db,
+		platform:            conf.Platform,
+		secret:              conf.JWTSecret,
+		apiKey:              conf.PolkaKey,
+		polkaSecret:         conf.PolkaWebhookSecret,
+		media:               blobStore,
+		maxUploadBytes:      conf.MediaMaxBytes,
+		maxBodyBytes:        conf.MaxBodyBytes,
+		accessTokenTTL:      conf.AccessTokenTTL,
+		refreshTokenTTL:     conf.RefreshTokenTTL,
+		editWindow:          conf.ChirpEditWindow,
+		restoreWindow:       conf.ChirpRestoreWindow,
+		filter:              profanity,
+		limiter:             limiter,
+		entitlements:        tiers,
+		trustProxyHeaders:   conf.TrustProxyHeaders,
+		webhookAllowPrivate: conf.WebhookAllowPrivateNetworks,
+	}
+	apiCfg.metrics = newMetrics(db, func() float64 { return float64(apiCfg.fileserverHits.Load()) })
+	jobs := newWorkers()
+	jobs.Go(func(ctx context.Context) { apiCfg.runPurgeJob(ctx, time.Hour) })
+	jobs.Go(func(ctx context.Context) { apiCfg.runSubscriptionExpiryJob(ctx, time.Minute) })
+	webhookWorker := &webhooks.Worker{
+		Store:  webhooks.NewPostgresStore(db, queries),
+		Client: &http.Client{Timeout: conf.WebhookTimeout, Transport: otelhttp.NewTransport(webhooks.NewTransport(conf.WebhookAllowPrivateNetworks))},
+	}
+	jobs.Go(func(ctx context.Context) {
+		webhookWorker.Run(ctx, 5*time.Second, func(err error) {
+			slog.Error("webhook delivery failed", "error", err)
+		})
+	})
+	jobs.Go(func(ctx context.Context) {
+		ratelimit.RunPruner(ctx, limiter, 10*time.Minute, 24*time.Hour, func(err error) {
+			slog.Error("could not prune rate limit buckets", "error", err)
+		})
+	})
+	if conf.ProfanityFile != "" {
+		jobs.Go(func(ctx context.Context) {
+			profanity.Watch(ctx, conf.ProfanityFile, conf.ProfanityWords, 30*time.Second)
+		})
 	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app/", http.FileServer(http.Dir(filepathRoot)))))
-	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.getMetrics)
-	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetOneChirp)
-	mux.HandleFunc("POST /api/login", apiCfg.handlerUserLogin)
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevokeRefresh)
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhook)
-
-	server := http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+	checks := health.NewRegistry(conf.HealthTimeout)
+	checks.Register("database", health.CheckFunc(db.PingContext))
+	checks.Register("schema", health.CheckFunc(migrator.Check))
+	if p, ok := blobStore.(media.Pinger); ok {
+		checks.Register("media", health.CheckFunc(p.Ping))
 	}
 
-	log.Printf("Servinbg files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(server.ListenAndServe())
+	mux := http.NewServeMux()
+	apiCfg.routes(mux.Handle, conf.FilepathRoot, checks)
 
-}
+	bodyLimits := map[string]int64{
+		"POST /api/chirps/{chirpID}/media": conf.MediaMaxBytes + (1 << 20),
+	}
+	handler := middlewareOperational(mux, middlewareShareUserLookup(apiCfg.middlewareRateLimit(globalPolicy, apiCfg.middlewareRejectSuspended(mux))))
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(conf.Port),
+		Handler:           apiCfg.middlewareTracing(mux, middlewareRequestID(apiCfg.metrics.middlewareMetrics(mux, apiCfg.middlewareAccessLog(mux, middlewareLimitBody(mux, conf.MaxBodyBytes, bodyLimits, handler))))),
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		ReadTimeout:       conf.ReadTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+		MaxHeaderBytes:    conf.MaxHeaderBytes,
+	}
 
-func handlerReadiness(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(http.StatusText(http.StatusOK)))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	slog.Info("serving", "port", conf.Port, "filepath_root", conf.FilepathRoot)
+	if err := serve(ctx, server, conf.ShutdownDelay, conf.ShutdownTimeout, checks.Drain); err != nil {
+		slog.Error("server stopped", "error", err)
+	}
+	jobs.Stop()
+	if err := db.Close(); err != nil {
+		slog.Error("could not close db", "error", err)
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Error("could not flush traces", "error", err)
+	}
+	slog.Info("shutdown complete")
 }
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	metrics        *metrics
 	db             *database.Queries
+	conn           *sql.DB
 	platform       string
 	secret         string
 	apiKey         string
+	polkaSecret    string
+	media          media.BlobStore
+	maxUploadBytes int64
+	maxBodyBytes   int64
+	// accessTokenTTL and refreshTokenTTL are how long issued tokens last.
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	editWindow      time.Duration
+	restoreWindow   time.Duration
+	filter          *filter.Filter
+	limiter         ratelimit.Store
+	entitlements    *entitlements.Table
+	// trustProxyHeaders makes clientIP believe X-Forwarded-For.
+	trustProxyHeaders bool
+	// webhookAllowPrivate lets webhook endpoints point at local and private
+	// addresses.
+	webhookAllowPrivate bool
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -106,27 +215,18 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "issue deleting resource", err)
 		return
 	}
+	cfg.audit(r, auditEntry{Action: auditAdminReset})
 	w.WriteHeader(http.StatusOK)
 }
 
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
 	type params struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
 	}
 	data := params{}
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "error reading data", err)
-		return
-	}
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "error unmarshalling data", err)
-		return
-	}
-	if _, err := mail.ParseAddress(data.Email); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid email", err)
+	if err := cfg.decodeJSON(w, r, &data); err != nil {
+		respondWithProblem(w, err)
 		return
 	}
 	hashed, err := auth.HashPassword(data.Password)
@@ -142,6 +242,10 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		HashedPassword: hashed,
 	}
 	newUser, err := cfg.db.CreateUser(r.Context(), user)
+	if isUniqueViolation(err) {
+		respondWithProblem(w, problem.New(http.StatusConflict, "email_taken", "email is already in use", err))
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "error saving to db", err)
 		return
@@ -160,15 +264,18 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		Email:       newUser.Email,
 		IsChirpyRed: newUser.IsChirpyRed,
 	}
+	cfg.publishAfter(r.Context(), eventUserCreated, newUser.ID, resUser)
 	respondWithJson(w, http.StatusCreated, resUser)
 }
 
 type chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Body      string       `json:"body"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Edited    bool         `json:"edited"`
+	Media     []attachment `json:"media"`
 }
 
 func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
@@ -183,60 +290,57 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// UserID is accepted for older clients but ignored: chirps are always
+	// posted as the token's user.
 	type params struct {
-		Body   string    `json:"body"`
+		Body   string    `json:"body" validate:"required"`
 		UserID uuid.UUID `json:"user_id"`
 	}
-	const maxChirpLength = 140
-	defer r.Body.Close()
-	d := json.NewDecoder(r.Body)
 	p := params{}
 	w.Header().Add("Content-Type", "application/json")
-	err = d.Decode(&p)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+	if err := cfg.decodeJSON(w, r, &p); err != nil {
+		respondWithProblem(w, err)
 		return
 	}
 	p.UserID = userID
-	if len(p.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	_, limits, err := cfg.limitsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	if utf8.RuneCountInString(p.Body) > limits.ChirpLength {
+		respondWithProblem(w, problem.Invalid("body", "too_long", fmt.Sprintf("Chirp is too long, the limit is %d characters", limits.ChirpLength)))
 		return
 	}
-	badWords := getBadWords()
 	chirpParam := database.CreateChirpParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
-		Body:      sanitisedChirp(p.Body, badWords),
+		Body:      cfg.filter.Clean(p.Body),
 		UserID:    p.UserID,
 	}
-	log.Printf("%v", chirpParam)
-
 	newChirp, err := cfg.db.CreateChirp(
 		r.Context(),
 		chirpParam,
 	)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "issue inserting in to database", err)
+		respondWithProblem(w, err)
 		return
 	}
-	type chirp struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserID    uuid.UUID `json:"user_id"`
-	}
-	respondWithJson(w, http.StatusCreated, chirp{ID: newChirp.ID, CreatedAt: newChirp.CreatedAt, UpdatedAt: newChirp.UpdatedAt, Body: newChirp.Body, UserID: newChirp.UserID})
+	res := chirp{ID: newChirp.ID, CreatedAt: newChirp.CreatedAt, UpdatedAt: newChirp.UpdatedAt, Body: newChirp.Body, UserID: newChirp.UserID, Media: []attachment{}}
+	cfg.metrics.chirpsCreated.Inc()
+	cfg.publishAfter(r.Context(), eventChirpCreated, newChirp.UserID, res)
+	respondWithJson(w, http.StatusCreated, res)
 }
 
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("author_id")
 	sortBy := r.URL.Query().Get("sort")
+	viewer := cfg.viewerID(r)
 	var chirps []database.Chirp
 	var err error
 	if userID == "" {
-		chirps, err = cfg.db.GetChirps(r.Context())
+		chirps, err = cfg.db.GetChirps(r.Context(), viewer)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
 			return
@@ -244,10 +348,13 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	} else {
 		uID, err := uuid.Parse(userID)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+			respondWithProblem(w, problem.Invalid("author_id", "uuid", "must be a UUID"))
 			return
 		}
-		chirps, err = cfg.db.GetChirpsByUserID(r.Context(), uID)
+		chirps, err = cfg.db.GetChirpsByUserID(r.Context(), database.GetChirpsByUserIDParams{
+			UserID:   uID,
+			ViewerID: viewer,
+		})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
 			return
@@ -263,77 +370,85 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 			return (chirps[i].CreatedAt.Compare(chirps[j].CreatedAt) >= 0)
 		})
 	}
-	theChirps := []chirp{}
-	for _, c := range chirps {
-		chrp := chirp{
-			ID:        c.ID,
-			CreatedAt: c.CreatedAt,
-			UpdatedAt: c.UpdatedAt,
-			Body:      c.Body,
-			UserID:    c.UserID,
-		}
-		theChirps = append(theChirps, chrp)
+	theChirps, err := cfg.chirpResponses(r.Context(), chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
 	}
 	respondWithJson(w, http.StatusOK, theChirps)
 }
 
 func (cfg *apiConfig) handlerGetOneChirp(w http.ResponseWriter, r *http.Request) {
-	var chirpID uuid.UUID
-	chirpID = uuid.MustParse(r.PathValue("chirpID"))
-	c, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	c, err := cfg.db.GetChirpByID(r.Context(), database.GetChirpByIDParams{
+		ID:       chirpID,
+		ViewerID: cfg.viewerID(r),
+	})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
 		return
 	}
-	chrp := chirp{
-		ID:        c.ID,
-		CreatedAt: c.CreatedAt,
-		UpdatedAt: c.UpdatedAt,
-		Body:      c.Body,
-		UserID:    c.UserID,
+	chrps, err := cfg.chirpResponses(r.Context(), []database.Chirp{c})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
 	}
-	respondWithJson(w, http.StatusOK, chrp)
+	respondWithJson(w, http.StatusOK, chrps[0])
+}
+
+// errInvalidCredentials answers both an unknown email and a wrong password,
+// so a failed login does not reveal whether the account exists.
+func errInvalidCredentials(err error) error {
+	return problem.New(http.StatusUnauthorized, "invalid_credentials", "email or password is incorrect", err)
 }
 
 func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 	type params struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" validate:"required"`
+		Password string `json:"password" validate:"required"`
 	}
 	data := params{}
-	d := json.NewDecoder(r.Body)
-	d.Decode(&data)
+	if err := cfg.decodeJSON(w, r, &data); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
 	storedUser, err := cfg.db.GetUserByEmail(r.Context(), data.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.audit(r, auditEntry{Action: auditLoginFailure, Metadata: map[string]any{"email": data.Email, "reason": "unknown_email"}})
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
+		respondWithProblem(w, errInvalidCredentials(err))
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "please try again", err)
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
 		return
 	}
 	if err := auth.CheckPasswordHash(data.Password, storedUser.HashedPassword); err != nil {
-		respondWithError(w, http.StatusUnauthorized, "please try again", err)
+		cfg.audit(r, auditEntry{Action: auditLoginFailure, Actor: actor(storedUser.ID), Metadata: map[string]any{"reason": "wrong_password"}})
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
+		respondWithProblem(w, errInvalidCredentials(err))
+		return
+	}
+	if isSuspended(storedUser, time.Now()) {
+		cfg.audit(r, auditEntry{Action: auditLoginFailure, Actor: actor(storedUser.ID), Metadata: map[string]any{"reason": "suspended"}})
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
+		respondWithError(w, http.StatusForbidden, suspendedMessage(storedUser), nil)
 		return
 	}
-	token, err := auth.MakeJWT(storedUser.ID, cfg.secret, calculateTimeout(60*60))
+	token, err := auth.MakeJWT(storedUser.ID, cfg.secret, cfg.accessTokenTTL)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "please try again", err)
+		respondWithError(w, http.StatusInternalServerError, "issue creating token", err)
 		return
 	}
-	refreshToken, err := auth.MakeRefreshToken()
+	refreshToken, err := cfg.issueRefreshToken(r.Context(), storedUser.ID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "please try again", err)
+		respondWithError(w, http.StatusInternalServerError, "issue creating token", err)
 		return
 	}
-	now := time.Now()
-	_, err = cfg.db.CreateRefreshToken(
-		r.Context(),
-		database.CreateRefreshTokenParams{
-			Token:     refreshToken,
-			CreatedAt: now,
-			UpdatedAt: now,
-			UserID:    storedUser.ID,
-			ExpiresAt: now.AddDate(0, 0, 60),
-			RevokedAt: sql.NullTime{Valid: false},
-		},
-	)
 	type User struct {
 		ID           uuid.UUID `json:"id"`
 		CreatedAt    time.Time `json:"created_at"`
@@ -352,24 +467,55 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 		RefreshToken: refreshToken,
 		IsChirpyRed:  storedUser.IsChirpyRed,
 	}
+	cfg.audit(r, auditEntry{Action: auditLoginSuccess, Actor: actor(storedUser.ID)})
+	cfg.metrics.logins.WithLabelValues("success").Inc()
 	respondWithJson(w, http.StatusOK, resUser)
 }
 
+func (cfg *apiConfig) issueRefreshToken(ctx context.Context, userID uuid.UUID) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	_, err = cfg.db.CreateRefreshToken(
+		ctx,
+		database.CreateRefreshTokenParams{
+			Token:     refreshToken,
+			CreatedAt: now,
+			UpdatedAt: now,
+			UserID:    userID,
+			ExpiresAt: now.Add(cfg.refreshTokenTTL),
+			RevokedAt: sql.NullTime{Valid: false},
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
 	}
 	rt, err := cfg.db.GetUserFromRefreshToken(r.Context(), token)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
 		return
 	}
-	newToken, err := auth.MakeJWT(rt.ID, cfg.secret, calculateTimeout(60*60))
+	if isSuspended(rt, time.Now()) {
+		respondWithError(w, http.StatusForbidden, suspendedMessage(rt), nil)
+		return
+	}
+	newToken, err := auth.MakeJWT(rt.ID, cfg.secret, cfg.accessTokenTTL)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "please try again", err)
+		respondWithError(w, http.StatusInternalServerError, "issue creating token", err)
 		return
 	}
+	cfg.audit(r, auditEntry{Action: auditTokenRefresh, Actor: actor(rt.ID)})
 	type rParam struct {
 		Token string `json:"token"`
 	}
@@ -382,6 +528,10 @@ func (cfg *apiConfig) handlerRevokeRefresh(w http.ResponseWriter, r *http.Reques
 		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
 		return
 	}
+	entry := auditEntry{Action: auditTokenRevoke}
+	if u, err := cfg.db.GetUserFromRefreshToken(r.Context(), token); err == nil {
+		entry.Actor = actor(u.ID)
+	}
 	now := time.Now()
 	err = cfg.db.RevokeRefreshToken(
 		r.Context(),
@@ -390,6 +540,9 @@ func (cfg *apiConfig) handlerRevokeRefresh(w http.ResponseWriter, r *http.Reques
 			RevokedAt: sql.NullTime{Time: now, Valid: true},
 			UpdatedAt: now,
 		})
+	if err == nil {
+		cfg.audit(r, entry)
+	}
 	w.WriteHeader(204)
 }
 
@@ -404,47 +557,103 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
 		return
 	}
+	// Email and Password are pointers so an omitted field can be told apart
+	// from one that was sent empty; omitted fields are left unchanged.
 	type params struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email           *string `json:"email" validate:"email"`
+		Password        *string `json:"password" validate:"min=1"`
+		CurrentPassword string  `json:"current_password" validate:"required"`
 	}
 	p := &params{}
-	d := json.NewDecoder(r.Body)
-	d.Decode(p)
+	if err := cfg.decodeJSON(w, r, p); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+	if p.Email == nil && p.Password == nil {
+		respondWithError(w, http.StatusBadRequest, "nothing to update", nil)
+		return
+	}
 	user, err := cfg.db.GetUserByID(r.Context(), userID)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
 		return
 	}
-	if userID != user.ID {
-		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+	if err := auth.CheckPasswordHash(p.CurrentPassword, user.HashedPassword); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "current password is incorrect", err)
 		return
 	}
-	hash, err := auth.HashPassword(p.Password)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), err)
-		return
+	email := user.Email
+	if p.Email != nil {
+		email = *p.Email
+	}
+	hash := user.HashedPassword
+	if p.Password != nil {
+		hash, err = auth.HashPassword(*p.Password)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), err)
+			return
+		}
 	}
 	updated, err := cfg.db.UpdateUser(
 		r.Context(),
 		database.UpdateUserParams{
 			ID:             user.ID,
-			Email:          p.Email,
+			Email:          email,
 			HashedPassword: hash,
 			UpdatedAt:      time.Now(),
 		})
+	if isUniqueViolation(err) {
+		respondWithProblem(w, problem.New(http.StatusConflict, "email_taken", "email is already in use", err))
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), err)
 		return
 	}
 	type response struct {
-		ID          uuid.UUID `json:"id"`
-		Email       string    `json:"email"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
-		IsChirpyRed bool      `json:"is_chirpy_red"`
+		ID           uuid.UUID `json:"id"`
+		Email        string    `json:"email"`
+		CreatedAt    time.Time `json:"created_at"`
+		UpdatedAt    time.Time `json:"updated_at"`
+		IsChirpyRed  bool      `json:"is_chirpy_red"`
+		RefreshToken string    `json:"refresh_token,omitempty"`
+	}
+	res := response{ID: updated.ID, Email: updated.Email, CreatedAt: updated.CreatedAt, UpdatedAt: updated.UpdatedAt, IsChirpyRed: updated.IsChirpyRed}
+	if updated.Email != user.Email {
+		cfg.audit(r, auditEntry{
+			Action:     auditEmailChange,
+			Actor:      actor(user.ID),
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+			Metadata:   map[string]any{"from": user.Email, "to": updated.Email},
+		})
+	}
+	if p.Password != nil {
+		// A password change signs out every other session. The caller gets a
+		// fresh refresh token so only their own session survives.
+		now := time.Now()
+		err = cfg.db.RevokeUserRefreshTokens(r.Context(), database.RevokeUserRefreshTokensParams{
+			RevokedAt: sql.NullTime{Time: now, Valid: true},
+			UpdatedAt: now,
+			UserID:    updated.ID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "issue revoking sessions", err)
+			return
+		}
+		cfg.audit(r, auditEntry{
+			Action:     auditPasswordChange,
+			Actor:      actor(user.ID),
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+		})
+		res.RefreshToken, err = cfg.issueRefreshToken(r.Context(), updated.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "issue creating session", err)
+			return
+		}
 	}
-	respondWithJson(w, http.StatusOK, response{ID: updated.ID, Email: updated.Email, CreatedAt: updated.CreatedAt, UpdatedAt: updated.UpdatedAt, IsChirpyRed: updated.IsChirpyRed})
+	respondWithJson(w, http.StatusOK, res)
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
@@ -463,52 +672,33 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
 		return
 	}
-	deleted, err := cfg.db.DeleteChirp(r.Context(), database.DeleteChirpParams{
-		ID:     chirpID,
-		UserID: userID,
+	deleted, err := cfg.db.SoftDeleteChirp(r.Context(), database.SoftDeleteChirpParams{
+		DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:        chirpID,
+		UserID:    userID,
 	})
-	if err != nil {
-		respondWithError(w, http.StatusForbidden, http.StatusText(http.StatusForbidden), err)
-		return
-	}
-	log.Printf("%v\n", deleted)
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
-	providedKey, err := auth.GetAPIKey(r.Header)
-	if err != nil || providedKey != cfg.apiKey {
-		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
-		return
-	}
-	type params struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
-	}
-	p := &params{}
-	d := json.NewDecoder(r.Body)
-	err = d.Decode(p)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "issue decoding body", err)
-		return
-	}
-	if p.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
+	if errors.Is(err, sql.ErrNoRows) {
+		// Nothing was deleted: either the chirp does not exist (or is already
+		// deleted) or it belongs to someone else.
+		existing, lookupErr := cfg.db.GetChirpByIDIncludingDeleted(r.Context(), chirpID)
+		if lookupErr == nil && !existing.DeletedAt.Valid {
+			err = fmt.Errorf("chirp belongs to another user: %w", problem.ErrForbidden)
+		}
 	}
-	err = cfg.db.UpgradeToRedByID(r.Context(), p.Data.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		respondWithProblem(w, err)
 		return
 	}
+	cfg.audit(r, auditEntry{
+		Action:     auditChirpDelete,
+		Actor:      actor(userID),
+		TargetType: "chirp",
+		TargetID:   deleted.ID.String(),
+	})
+	cfg.publishAfter(r.Context(), eventChirpDeleted, deleted.UserID, chirpDeleted{
+		ID:        deleted.ID,
+		UserID:    deleted.UserID,
+		DeletedAt: deleted.DeletedAt.Time,
+	})
 	w.WriteHeader(http.StatusNoContent)
 }
-
-func calculateTimeout(seconds int) time.Duration {
-	if seconds == 0 || seconds >= 60 {
-		return time.Duration(int64(time.Second) * 60 * 60)
-	}
-	return time.Duration(int64(time.Second) * int64(seconds))
-}
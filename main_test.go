@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginFailuresLookAlike(t *testing.T) {
+	s := loadSpec(t)
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	user := database.User{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Email: "a@example.com", HashedPassword: hash}
+
+	login := func(t *testing.T, ts *testServer) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"a@example.com","password":"wrong"}`))
+		req.Header.Set("Content-Type", "application/json")
+		pattern, rec := ts.do(req)
+		s.checkResponse(t, pattern, rec)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	ts := newTestServer(t)
+	ts.mock.ExpectQuery("GetUserByEmail").WillReturnRows(emptyRows(database.User{}))
+	ts.mock.ExpectExec("CreateAuditEvent").WillReturnResult(sqlmock.NewResult(0, 1))
+	unknown := login(t, ts)
+
+	ts = newTestServer(t)
+	ts.mock.ExpectQuery("GetUserByEmail").WillReturnRows(rows(user))
+	ts.mock.ExpectExec("CreateAuditEvent").WillReturnResult(sqlmock.NewResult(0, 1))
+	wrong := login(t, ts)
+
+	for name, rec := range map[string]*httptest.ResponseRecorder{"unknown email": unknown, "wrong password": wrong} {
+		if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `"code":"invalid_credentials"`) {
+			t.Errorf("%s: expected 401 invalid_credentials, got %d: %s", name, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestCreateChirpDatabaseErrors(t *testing.T) {
+	s := loadSpec(t)
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	user := database.User{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "a@example.com"}
+
+	post := func(t *testing.T, ts *testServer) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body":"hello"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token(t, userID))
+		pattern, rec := ts.do(req)
+		s.checkResponse(t, pattern, rec)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	t.Run("loading the user's limits", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnError(errors.New("connection reset"))
+		if rec := post(t, ts); rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("inserting the chirp", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(user))
+		ts.mock.ExpectQuery("CreateChirp").WillReturnError(errors.New("connection reset"))
+		if rec := post(t, ts); rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestChirpLengthCountsCharacters(t *testing.T) {
+	s := loadSpec(t)
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	free := database.User{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "a@example.com"}
+	red := free
+	red.IsChirpyRed = true
+
+	post := func(t *testing.T, ts *testServer, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body":"`+body+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token(t, userID))
+		pattern, rec := ts.do(req)
+		s.checkResponse(t, pattern, rec)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+	testCases := []struct {
+		Name string
+		User database.User
+		Body string
+		Code int
+	}{
+		{"free user at the limit in multibyte characters", free, strings.Repeat("é", 140), http.StatusCreated},
+		{"free user over the limit", free, strings.Repeat("a", 141), http.StatusBadRequest},
+		{"Chirpy Red user over the free limit", red, strings.Repeat("é", 300), http.StatusCreated},
+	}
+	for _, c := range testCases {
+		t.Run(c.Name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(c.User))
+			if c.Code == http.StatusCreated {
+				ts.mock.ExpectQuery("CreateChirp").WillReturnRows(rows(database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: c.Body, UserID: userID}))
+				ts.mock.ExpectQuery("ListWebhookEndpointsForEvent").WillReturnRows(emptyRows(database.WebhookEndpoint{}))
+			}
+			rec := post(t, ts, c.Body)
+			if rec.Code != c.Code {
+				t.Fatalf("expected %d, got %d: %s", c.Code, rec.Code, rec.Body)
+			}
+			if c.Code == http.StatusBadRequest && !strings.Contains(rec.Body.String(), `"code":"too_long"`) {
+				t.Errorf("expected a too_long error, got %s", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// metrics holds the Prometheus collectors served on /metrics. The
+// registry is our own rather than the global one so tests and the admin
+// page see only what the server registered.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+
+	chirpsCreated prometheus.Counter
+	logins        *prometheus.CounterVec
+	webhookEvents *prometheus.CounterVec
+}
+
+// newMetrics registers the HTTP, business, Go runtime and database pool
+// collectors. fileserverHits is read on every scrape, so /metrics and the
+// admin page always agree.
+func newMetrics(db *sql.DB, fileserverHits func() float64) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests served, by route pattern and status code.",
+		}, []string{"route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chirpy_http_requests_in_flight",
+			Help: "HTTP requests currently being served.",
+		}),
+		chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts, by result.",
+		}, []string{"result"}),
+		webhookEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_events_total",
+			Help: "Inbound webhook events processed, by source, event and resulting status.",
+		}, []string{"source", "event", "status"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.chirpsCreated,
+		m.logins,
+		m.webhookEvents,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests for the static app under /app/ since the last reset.",
+		}, fileserverHits),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "chirpy"),
+	)
+	return m
+}
+
+// handler serves the registry in the Prometheus text format. It is mounted on
+// the public mux without authentication on purpose: nothing in the registry
+// is per user, and scrapers should not need a token.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// middlewareMetrics counts and times every request. The route label is the
+// mux pattern, which includes the method. Requests that match no route share
+// the "unmatched" label so scanners cannot blow up the number of series.
+func (m *metrics) middlewareMetrics(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, ctx: r.Context()}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.WithLabelValues(route, strconv.Itoa(status)).Inc()
+		m.requestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	})
+}
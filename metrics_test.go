@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsRouteLabels(t *testing.T) {
+	ts := newTestServer(t)
+	handler := ts.cfg.metrics.middlewareMetrics(ts.mux, ts.handler)
+	paths := []string{"/api/livez", "/wp-login.php", "/.env", "/api/nope/1", "/api/nope/2"}
+	for _, path := range paths {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/api/livez", nil))
+
+	requests := ts.cfg.metrics.requests
+	if n := testutil.CollectAndCount(requests); n != 3 {
+		t.Fatalf("expected 3 series, got %d", n)
+	}
+	if n := testutil.ToFloat64(requests.WithLabelValues("GET /api/livez", "200")); n != 1 {
+		t.Errorf("expected 1 request for GET /api/livez, got %v", n)
+	}
+	if n := testutil.ToFloat64(requests.WithLabelValues("unmatched", "404")); n != 4 {
+		t.Errorf("expected 4 unmatched 404s, got %v", n)
+	}
+	if n := testutil.ToFloat64(requests.WithLabelValues("unmatched", "405")); n != 1 {
+		t.Errorf("expected 1 unmatched 405, got %v", n)
+	}
+}
+
+func TestMetricsMatchAdminPage(t *testing.T) {
+	ts := newTestServer(t)
+	get := func(path string) string {
+		_, rec := ts.do(httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d", path, rec.Code)
+		}
+		return rec.Body.String()
+	}
+	for i := 0; i < 3; i++ {
+		get("/app/")
+	}
+	if page := get("/admin/metrics"); !strings.Contains(page, "visited 3 times") {
+		t.Errorf("expected the admin page to show 3 visits, got %s", page)
+	}
+	if scrape := get("/metrics"); !strings.Contains(scrape, "\nchirpy_fileserver_hits_total 3\n") {
+		t.Errorf("expected /metrics to report 3 visits, got:\n%s", scrape)
+	}
+}
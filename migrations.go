@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MattInReality/Chirpy/internal/config"
+	"github.com/MattInReality/Chirpy/internal/migrate"
+	"github.com/MattInReality/Chirpy/sql/schema"
+	"log/slog"
+	"os"
+)
+
+const migrateUsage = "usage: chirpy migrate up|down|status [flags]"
+
+// runMigrate handles `chirpy migrate <action>`. The remaining arguments are
+// the usual configuration flags, so the database comes from the same places
+// as it does for the server.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	action := args[0]
+	conf, err := config.Load(config.Options{
+		Args:      args[1:],
+		LookupEnv: os.LookupEnv,
+		DotEnv:    ".env",
+	})
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	slog.SetDefault(newLogger(conf, os.Stderr))
+	db, err := sql.Open("postgres", conf.DBURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := migrate.New(db, schema.FS)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	switch action {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "status":
+		return migrator.Status(ctx, os.Stdout)
+	default:
+		return fmt.Errorf("unknown migrate action %q\n%s", action, migrateUsage)
+	}
+}
+
+// prepareSchema brings the database up to date when migrate_on_start is set
+// and otherwise refuses to continue if any migration is missing. The migrator
+// is returned so readiness can keep checking the schema.
+func prepareSchema(ctx context.Context, conf *config.Config, db *sql.DB) (*migrate.Migrator, error) {
+	migrator, err := migrate.New(db, schema.FS)
+	if err != nil {
+		return nil, err
+	}
+	if conf.MigrateOnStart {
+		return migrator, migrator.Up(ctx)
+	}
+	if err := migrator.Check(ctx); err != nil {
+		return nil, fmt.Errorf("%w; run `chirpy migrate up` or set migrate_on_start", err)
+	}
+	return migrator, nil
+}
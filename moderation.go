@@ -0,0 +1,97 @@
+package main
+
+import (
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+type moderatorChirp struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Edited    bool       `json:"edited"`
+	DeletedAt *time.Time `json:"deleted_at"`
+	HiddenAt  *time.Time `json:"hidden_at"`
+}
+
+func moderatorChirpFromRow(c database.Chirp) moderatorChirp {
+	mc := moderatorChirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID,
+		Edited:    c.EditedAt.Valid,
+	}
+	if c.DeletedAt.Valid {
+		mc.DeletedAt = &c.DeletedAt.Time
+	}
+	if c.HiddenAt.Valid {
+		mc.HiddenAt = &c.HiddenAt.Time
+	}
+	return mc
+}
+
+// requireModerator authenticates the request and checks the caller is a
+// moderator. It writes the error response itself and reports whether the
+// handler may continue.
+func (cfg *apiConfig) requireModerator(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return database.User{}, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return database.User{}, false
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return database.User{}, false
+	}
+	if !user.IsModerator {
+		respondWithError(w, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (cfg *apiConfig) handlerModeratorDeletedChirps(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireModerator(w, r); !ok {
+		return
+	}
+	chirps, err := cfg.db.GetDeletedChirps(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	res := []moderatorChirp{}
+	for _, c := range chirps {
+		res = append(res, moderatorChirpFromRow(c))
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) handlerModeratorGetChirp(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireModerator(w, r); !ok {
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	c, err := cfg.db.GetChirpByIDIncludingDeleted(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, moderatorChirpFromRow(c))
+}
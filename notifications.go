@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+func notify(ctx context.Context, q *database.Queries, userID uuid.UUID, kind, message string) error {
+	_, err := q.CreateNotification(ctx, database.CreateNotificationParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    userID,
+		Kind:      kind,
+		Message:   message,
+	})
+	return err
+}
+
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	rows, err := cfg.db.GetNotificationsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	type notification struct {
+		ID        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		Kind      string    `json:"kind"`
+		Message   string    `json:"message"`
+		Read      bool      `json:"read"`
+	}
+	res := []notification{}
+	for _, n := range rows {
+		res = append(res, notification{
+			ID:        n.ID,
+			CreatedAt: n.CreatedAt,
+			Kind:      n.Kind,
+			Message:   n.Message,
+			Read:      n.ReadAt.Valid,
+		})
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) handlerMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	n, err := cfg.db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ReadAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue updating notification", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/MattInReality/Chirpy/api"
+	"net/http"
+)
+
+func handlerOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(api.Spec)
+}
+
+func handlerDocs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(api.Docs)
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MattInReality/Chirpy/api"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/entitlements"
+	"github.com/MattInReality/Chirpy/internal/filter"
+	"github.com/MattInReality/Chirpy/internal/health"
+	"github.com/MattInReality/Chirpy/internal/media"
+	"github.com/MattInReality/Chirpy/internal/ratelimit"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// These tests keep api/openapi.json honest: every registered route must be
+// described, and real handler responses must match what the document says.
+// The database is mocked, so only the queries a case needs are set up.
+
+const (
+	testSecret = "contract-test-secret"
+	specURL    = "https://chirpy.test/openapi.json"
+)
+
+type spec struct {
+	doc      map[string]any
+	compiler *jsonschema.Compiler
+	schemas  map[string]*jsonschema.Schema
+}
+
+func loadSpec(t *testing.T) *spec {
+	t.Helper()
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(api.Spec))
+	if err != nil {
+		t.Fatalf("api/openapi.json is not valid JSON: %v", err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(specURL, doc); err != nil {
+		t.Fatal(err)
+	}
+	return &spec{doc: doc.(map[string]any), compiler: c, schemas: map[string]*jsonschema.Schema{}}
+}
+
+// schema compiles the schema at a JSON pointer such as
+// "/components/schemas/Chirp".
+func (s *spec) schema(t *testing.T, pointer string) *jsonschema.Schema {
+	t.Helper()
+	if sch, ok := s.schemas[pointer]; ok {
+		return sch
+	}
+	sch, err := s.compiler.Compile(specURL + "#" + pointer)
+	if err != nil {
+		t.Fatalf("could not compile schema %s: %v", pointer, err)
+	}
+	s.schemas[pointer] = sch
+	return sch
+}
+
+// lookup follows a JSON pointer, resolving any $ref it ends on, and returns
+// the value with the pointer it was actually found at.
+func (s *spec) lookup(pointer string) (any, string) {
+	var v any = s.doc
+	for _, tok := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, pointer
+		}
+		tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+		if v, ok = m[tok]; !ok {
+			return nil, pointer
+		}
+	}
+	if m, ok := v.(map[string]any); ok {
+		if ref, ok := m["$ref"].(string); ok {
+			return s.lookup(strings.TrimPrefix(ref, "#"))
+		}
+	}
+	return v, pointer
+}
+
+func escape(tok string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(tok, "~", "~0"), "/", "~1")
+}
+
+type operation struct {
+	method string
+	path   string
+	op     map[string]any
+}
+
+func (s *spec) operations() []operation {
+	var ops []operation
+	for path, item := range s.doc["paths"].(map[string]any) {
+		for method, op := range item.(map[string]any) {
+			ops = append(ops, operation{method: strings.ToUpper(method), path: path, op: op.(map[string]any)})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].path+ops[i].method < ops[j].path+ops[j].method
+	})
+	return ops
+}
+
+// specPath turns a ServeMux pattern into the method and path it is
+// documented under. A pattern without a method serves every method; it is
+// documented as GET, with its trailing wildcard spelled out.
+func specPath(pattern string) (method, path string) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = http.MethodGet, pattern
+	}
+	if strings.HasSuffix(path, "/") {
+		path += "{path}"
+	}
+	return method, path
+}
+
+// checkResponse fails the test unless the response to a request matched by
+// pattern is documented and its body satisfies the documented schema.
+func (s *spec) checkResponse(t *testing.T, pattern string, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	method, path := specPath(pattern)
+	opPointer := "/paths/" + escape(path) + "/" + strings.ToLower(method)
+	if op, _ := s.lookup(opPointer); op == nil {
+		t.Fatalf("%s %s is not documented", method, path)
+	}
+	status := strconv.Itoa(rec.Code)
+	response, respPointer := s.lookup(opPointer + "/responses/" + status)
+	if response == nil && rec.Code >= 500 {
+		response, respPointer = s.lookup(opPointer + "/responses/default")
+	}
+	if response == nil {
+		t.Fatalf("%s %s answered %d, which is not documented; body: %s", method, path, rec.Code, rec.Body)
+	}
+	content, _ := response.(map[string]any)["content"].(map[string]any)
+	if len(content) == 0 {
+		if rec.Body.Len() > 0 {
+			t.Fatalf("%s %s %d is documented without a body but sent %q", method, path, rec.Code, rec.Body)
+		}
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("%s %s %d has no usable Content-Type: %v", method, path, rec.Code, err)
+	}
+	documented := ""
+	for mt := range content {
+		if mt == mediaType || mt == "*/*" || strings.HasSuffix(mt, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mt, "*")) {
+			documented = mt
+			break
+		}
+	}
+	if documented == "" {
+		t.Fatalf("%s %s %d sent %s, documented: %v", method, path, rec.Code, mediaType, keys(content))
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return
+	}
+	body, err := jsonschema.UnmarshalJSON(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("%s %s %d sent invalid JSON: %v", method, path, rec.Code, err)
+	}
+	sch := s.schema(t, respPointer+"/content/"+escape(documented)+"/schema")
+	if err := sch.Validate(body); err != nil {
+		t.Fatalf("%s %s %d does not match the spec: %v\nbody: %s", method, path, rec.Code, err, rec.Body)
+	}
+}
+
+func keys(m map[string]any) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+type testServer struct {
+	cfg     *apiConfig
+	mux     *http.ServeMux
+	handler http.Handler
+	mock    sqlmock.Sqlmock
+}
+
+// newTestServer builds the routes over a mock database. Queries are matched
+// by their sqlc name, so expectations read like mock.ExpectQuery("GetChirps").
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(func(name, query string) error {
+		if strings.Contains(query, "-- name: "+name+" ") {
+			return nil
+		}
+		first, _, _ := strings.Cut(query, "\n")
+		return fmt.Errorf("want query %s, got %s", name, first)
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	store, err := media.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiers, err := entitlements.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &apiConfig{
+		db:              database.New(db),
+		conn:            db,
+		platform:        "test",
+		secret:          testSecret,
+		apiKey:          "polka-key",
+		media:           store,
+		maxUploadBytes:  1 << 20,
+		maxBodyBytes:    4 << 10,
+		accessTokenTTL:  time.Hour,
+		refreshTokenTTL: time.Hour,
+		editWindow:      time.Hour,
+		restoreWindow:   time.Hour,
+		filter:          filter.New(filter.DefaultWords, filter.MaskFixed),
+		limiter:         ratelimit.NewMemoryStore(),
+		entitlements:    tiers,
+	}
+	cfg.metrics = newMetrics(db, func() float64 { return float64(cfg.fileserverHits.Load()) })
+	mux := http.NewServeMux()
+	cfg.routes(mux.Handle, t.TempDir(), health.NewRegistry(time.Second))
+	return &testServer{
+		cfg:     cfg,
+		mux:     mux,
+		handler: middlewareRequestID(cfg.middlewareAccessLog(mux, middlewareLimitBody(mux, cfg.maxBodyBytes, nil, middlewareShareUserLookup(mux)))),
+		mock:    mock,
+	}
+}
+
+func (ts *testServer) do(req *http.Request) (string, *httptest.ResponseRecorder) {
+	_, pattern := ts.mux.Handler(req)
+	rec := httptest.NewRecorder()
+	ts.handler.ServeHTTP(rec, req)
+	return pattern, rec
+}
+
+func token(t *testing.T, userID uuid.UUID) string {
+	t.Helper()
+	tok, err := auth.MakeJWT(userID, testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+// rows returns mock rows with one column per field of the database structs
+// given, in field order, which is the order sqlc scans them in.
+func rows(values ...any) *sqlmock.Rows {
+	r := emptyRows(values[0])
+	for _, v := range values {
+		rv := reflect.ValueOf(v)
+		row := make([]driver.Value, rv.NumField())
+		for i := range row {
+			f := rv.Field(i).Interface()
+			if ss, ok := f.([]string); ok {
+				f = pq.Array(ss)
+			}
+			if valuer, ok := f.(driver.Valuer); ok {
+				f, _ = valuer.Value()
+			}
+			row[i] = f
+		}
+		r.AddRow(row...)
+	}
+	return r
+}
+
+// emptyRows returns the columns of a database struct with no rows.
+func emptyRows(v any) *sqlmock.Rows {
+	t := reflect.TypeOf(v)
+	cols := make([]string, t.NumField())
+	for i := range cols {
+		cols[i] = t.Field(i).Name
+	}
+	return sqlmock.NewRows(cols)
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	s := loadSpec(t)
+	ts := newTestServer(t)
+	registered := map[string]bool{}
+	ts.cfg.routes(func(pattern string, _ http.Handler) {
+		method, path := specPath(pattern)
+		registered[method+" "+path] = true
+	}, t.TempDir(), health.NewRegistry(time.Second))
+
+	documented := map[string]bool{}
+	for _, op := range s.operations() {
+		documented[op.method+" "+op.path] = true
+	}
+	for route := range registered {
+		if !documented[route] {
+			t.Errorf("%s is registered but not in api/openapi.json", route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			t.Errorf("%s is in api/openapi.json but not registered", route)
+		}
+	}
+}
+
+func TestOpenAPIDocumentIsValid(t *testing.T) {
+	s := loadSpec(t)
+	if v := s.doc["openapi"]; v != "3.1.0" {
+		t.Fatalf("openapi = %v, want 3.1.0", v)
+	}
+	schemes := s.doc["components"].(map[string]any)["securitySchemes"].(map[string]any)
+	for _, name := range []string{"bearerAuth", "ApiKey"} {
+		if _, ok := schemes[name]; !ok {
+			t.Errorf("security scheme %s is missing", name)
+		}
+	}
+	for name := range s.doc["components"].(map[string]any)["schemas"].(map[string]any) {
+		s.schema(t, "/components/schemas/"+escape(name))
+	}
+
+	ids := map[string]bool{}
+	for _, op := range s.operations() {
+		where := op.method + " " + op.path
+		id, _ := op.op["operationId"].(string)
+		if id == "" || ids[id] {
+			t.Errorf("%s: operationId %q is missing or not unique", where, id)
+		}
+		ids[id] = true
+		security, ok := op.op["security"].([]any)
+		if !ok {
+			t.Errorf("%s: security is not declared", where)
+		}
+		for _, req := range security {
+			for name := range req.(map[string]any) {
+				if _, ok := schemes[name]; !ok {
+					t.Errorf("%s: unknown security scheme %s", where, name)
+				}
+			}
+		}
+		responses, _ := op.op["responses"].(map[string]any)
+		if _, ok := responses["default"]; !ok {
+			t.Errorf("%s: no default response", where)
+		}
+		for status := range responses {
+			pointer := "/paths/" + escape(op.path) + "/" + strings.ToLower(op.method) + "/responses/" + status
+			r, at := s.lookup(pointer)
+			if r == nil {
+				t.Errorf("%s %s: response does not resolve", where, status)
+				continue
+			}
+			content, _ := r.(map[string]any)["content"].(map[string]any)
+			for mt := range content {
+				s.schema(t, at+"/content/"+escape(mt)+"/schema")
+			}
+		}
+	}
+}
+
+// concretePath fills in a documented path's parameters.
+func concretePath(path string) string {
+	for {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			return path
+		}
+		end := strings.Index(path, "}")
+		path = path[:start] + uuid.NewString() + path[end+1:]
+	}
+}
+
+func TestSecuredRoutesRejectAnonymousRequests(t *testing.T) {
+	s := loadSpec(t)
+	ts := newTestServer(t)
+	for _, op := range s.operations() {
+		security, _ := op.op["security"].([]any)
+		anonymous := len(security) == 0
+		for _, req := range security {
+			if len(req.(map[string]any)) == 0 {
+				anonymous = true
+			}
+		}
+		if anonymous {
+			continue
+		}
+		t.Run(op.method+" "+op.path, func(t *testing.T) {
+			req := httptest.NewRequest(op.method, concretePath(op.path), strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			pattern, rec := ts.do(req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("expected 401, got %d: %s", rec.Code, rec.Body)
+			}
+			s.checkResponse(t, pattern, rec)
+		})
+	}
+	if err := ts.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResponsesMatchSpec(t *testing.T) {
+	s := loadSpec(t)
+	ts := newTestServer(t)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	userID := uuid.New()
+	hashed, err := auth.HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := database.User{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "mo@example.com", HashedPassword: hashed}
+	moderator := user
+	moderator.IsModerator = true
+	c := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: userID}
+	deleted := c
+	deleted.DeletedAt = sql.NullTime{Time: now, Valid: true}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		ctype  string
+		token  bool
+		expect func(m sqlmock.Sqlmock)
+		status int
+	}{
+		{
+			name: "create user", method: "POST", path: "/api/users", body: `{"email":"mo@example.com","password":"hunter2"}`,
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("CreateUser").WillReturnRows(rows(database.CreateUserRow{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "mo@example.com"}))
+				m.ExpectQuery("ListWebhookEndpointsForEvent").WillReturnRows(emptyRows(database.WebhookEndpoint{}))
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "create user with a taken email", method: "POST", path: "/api/users", body: `{"email":"mo@example.com","password":"hunter2"}`,
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("CreateUser").WillReturnError(&pq.Error{Code: "23505"})
+			},
+			status: http.StatusConflict,
+		},
+		{name: "create user with invalid fields", method: "POST", path: "/api/users", body: `{"email":"nope"}`, status: http.StatusBadRequest},
+		{name: "create user with an unknown field", method: "POST", path: "/api/users", body: `{"email":"mo@example.com","password":"x","admin":true}`, status: http.StatusBadRequest},
+		{name: "create user with a huge body", method: "POST", path: "/api/users", body: `{"email":"` + strings.Repeat("a", 5<<10) + `"}`, status: http.StatusRequestEntityTooLarge},
+		{
+			name: "log in", method: "POST", path: "/api/login", body: `{"email":"mo@example.com","password":"hunter2"}`,
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("GetUserByEmail").WillReturnRows(rows(user))
+				m.ExpectQuery("CreateRefreshToken").WillReturnRows(rows(database.RefreshToken{Token: "rt", CreatedAt: now, UpdatedAt: now, UserID: userID, ExpiresAt: now}))
+				m.ExpectExec("CreateAuditEvent").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			status: http.StatusOK,
+		},
+		{name: "log in with plain text", method: "POST", path: "/api/login", body: "hi", ctype: "text/plain", status: http.StatusUnsupportedMediaType},
+		{name: "log in with broken JSON", method: "POST", path: "/api/login", body: `{"email":`, status: http.StatusBadRequest},
+		{
+			name: "refresh with an unknown token", method: "POST", path: "/api/refresh", token: true,
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("GetUserFromRefreshToken").WillReturnError(sql.ErrNoRows)
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "list chirps", method: "GET", path: "/api/chirps?sort=desc",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("GetChirps").WillReturnRows(rows(c))
+				m.ExpectQuery("GetAttachmentsForChirps").WillReturnRows(emptyRows(database.ChirpAttachment{}))
+			},
+			status: http.StatusOK,
+		},
+		{name: "list chirps by a bad author", method: "GET", path: "/api/chirps?author_id=nope", status: http.StatusBadRequest},
+		{
+			name: "get a chirp", method: "GET", path: "/api/chirps/" + c.ID.String(),
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("GetChirpByID").WillReturnRows(rows(c))
+				m.ExpectQuery("GetAttachmentsForChirps").WillReturnRows(emptyRows(database.ChirpAttachment{}))
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "get a missing chirp", method: "GET", path: "/api/chirps/" + uuid.NewString(),
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("GetChirpByID").WillReturnError(sql.ErrNoRows)
+			},
+			status: http.StatusNotFound,
+		},
+		{name: "get a chirp with a bad id", method: "GET", path: "/api/chirps/nope", status: http.StatusNotFound},
+		{name: "post an empty chirp", method: "POST", path: "/api/chirps", body: `{}`, token: true, status: http.StatusBadRequest},
+		{
+			name: "get a profile", method: "GET", path: "/api/users/" + userID.String(),
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("GetPublicProfileByID").WillReturnRows(rows(database.GetPublicProfileByIDRow{
+					ID: userID, CreatedAt: now, Handle: sql.NullString{String: "mo", Valid: true}, DisplayName: "Mo", ChirpCount: 1,
+				}))
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "moderator route as a regular user", method: "GET", path: "/admin/reports", token: true,
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("GetUserByID").WillReturnRows(rows(user))
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "moderator views a deleted chirp", method: "GET", path: "/admin/chirps/" + c.ID.String(), token: true,
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("GetUserByID").WillReturnRows(rows(moderator))
+				m.ExpectQuery("GetChirpByIDIncludingDeleted").WillReturnRows(rows(deleted))
+			},
+			status: http.StatusOK,
+		},
+		{name: "reset outside dev", method: "POST", path: "/admin/reset", status: http.StatusForbidden},
+		{name: "liveness", method: "GET", path: "/api/livez", status: http.StatusOK},
+		{name: "readiness", method: "GET", path: "/api/readyz", status: http.StatusOK},
+		{name: "verbose readiness", method: "GET", path: "/api/healthz?verbose", status: http.StatusOK},
+		{name: "prometheus metrics", method: "GET", path: "/metrics", status: http.StatusOK},
+		{name: "admin metrics", method: "GET", path: "/admin/metrics", status: http.StatusOK},
+		{name: "openapi document", method: "GET", path: "/api/openapi.json", status: http.StatusOK},
+		{name: "docs page", method: "GET", path: "/api/docs", status: http.StatusOK},
+		{name: "missing app file", method: "GET", path: "/app/missing.js", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.expect != nil {
+				tt.expect(ts.mock)
+			}
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			if tt.body != "" {
+				ctype := tt.ctype
+				if ctype == "" {
+					ctype = "application/json"
+				}
+				req.Header.Set("Content-Type", ctype)
+			}
+			if tt.token {
+				req.Header.Set("Authorization", "Bearer "+token(t, userID))
+			}
+			pattern, rec := ts.do(req)
+			if rec.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			s.checkResponse(t, pattern, rec)
+			if err := ts.mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestOpenAPIDocumentIsServed(t *testing.T) {
+	ts := newTestServer(t)
+	_, rec := ts.do(httptest.NewRequest("GET", "/api/openapi.json", nil))
+	var doc map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not decode the served document: %v", err)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), api.Spec) {
+		t.Fatal("served document differs from api/openapi.json")
+	}
+	_, rec = ts.do(httptest.NewRequest("GET", "/api/docs", nil))
+	if !strings.Contains(rec.Body.String(), "/api/openapi.json") {
+		t.Fatal("docs page does not load the document")
+	}
+}
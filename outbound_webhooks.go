@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"github.com/MattInReality/Chirpy/internal/tracing"
+	"github.com/MattInReality/Chirpy/internal/validate"
+	"github.com/MattInReality/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Events sent to registered webhook endpoints.
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserCreated  = "user.created"
+	eventUserUpgraded = "user.upgraded"
+)
+
+// chirpDeleted is the data of a chirp.deleted event.
+type chirpDeleted struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// userUpgraded is the data of a user.upgraded event.
+type userUpgraded struct {
+	UserID uuid.UUID `json:"user_id"`
+	Plan   string    `json:"plan"`
+}
+
+var webhookEventTypes = map[string]bool{
+	eventChirpCreated: true,
+	eventChirpDeleted: true,
+	eventUserCreated:  true,
+	eventUserUpgraded: true,
+}
+
+func init() {
+	validate.Register("webhook_event", "is not a known event", func(s string) bool { return webhookEventTypes[s] })
+}
+
+// publish queues an event for every endpoint subscribed to it. App endpoints
+// get every event; a user's endpoints only get events about that user.
+// Passing the transaction's queries keeps the deliveries with the change
+// that caused them.
+func publish(ctx context.Context, q *database.Queries, event string, subject uuid.UUID, data any) error {
+	endpoints, err := q.ListWebhookEndpointsForEvent(ctx, database.ListWebhookEndpointsForEventParams{
+		Event:     event,
+		SubjectID: uuid.NullUUID{UUID: subject, Valid: true},
+	})
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+	now := time.Now().UTC()
+	payload, err := webhooks.NewPayload(uuid.New(), event, now, data)
+	if err != nil {
+		return err
+	}
+	for _, e := range endpoints {
+		err := q.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			ID:            uuid.New(),
+			EndpointID:    e.ID,
+			Event:         event,
+			Payload:       payload,
+			NextAttemptAt: now,
+			Traceparent:   tracing.Traceparent(ctx),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishAfter publishes an event for a change that has already been saved.
+// A failure is logged rather than failing the request.
+func (cfg *apiConfig) publishAfter(ctx context.Context, event string, subject uuid.UUID, data any) {
+	if err := publish(ctx, cfg.db, event, subject, data); err != nil {
+		logger(ctx).ErrorContext(ctx, "could not queue webhook", "event", event, "error", err)
+	}
+}
+
+// webhookOwnerHandler serves the endpoint management API for one owner: a
+// user, or the app itself when owner is null.
+type webhookOwnerHandler func(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID)
+
+func (cfg *apiConfig) userWebhooks(h webhookOwnerHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+			return
+		}
+		userID, err := auth.ValidateJWT(token, cfg.secret)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+			return
+		}
+		h(w, r, uuid.NullUUID{UUID: userID, Valid: true})
+	}
+}
+
+type webhookModeratorKey struct{}
+
+func (cfg *apiConfig) appWebhooks(h webhookOwnerHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		moderator, ok := cfg.requireModerator(w, r)
+		if !ok {
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), webhookModeratorKey{}, moderator.ID))
+		h(w, r, uuid.NullUUID{})
+	}
+}
+
+// auditAppWebhook records a change a moderator made to the app's own
+// webhooks. Users managing their own endpoints are not audited.
+func (cfg *apiConfig) auditAppWebhook(r *http.Request, owner uuid.NullUUID, e auditEntry) {
+	moderator, ok := r.Context().Value(webhookModeratorKey{}).(uuid.UUID)
+	if owner.Valid || !ok {
+		return
+	}
+	e.Actor = actor(moderator)
+	cfg.audit(r, e)
+}
+
+type webhookEndpoint struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    *uuid.UUID `json:"user_id"`
+	URL       string     `json:"url"`
+	Events    []string   `json:"events"`
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	// Secret is only returned when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+func webhookEndpointFromRow(e database.WebhookEndpoint) webhookEndpoint {
+	res := webhookEndpoint{
+		ID:        e.ID,
+		URL:       e.Url,
+		Events:    e.Events,
+		Active:    e.Active,
+		CreatedAt: e.CreatedAt,
+	}
+	if e.UserID.Valid {
+		res.UserID = &e.UserID.UUID
+	}
+	if res.Events == nil {
+		res.Events = []string{}
+	}
+	return res
+}
+
+func (cfg *apiConfig) handlerCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	type params struct {
+		URL    string   `json:"url" validate:"required,url"`
+		Events []string `json:"events" validate:"webhook_event"`
+	}
+	p := params{}
+	if err := cfg.decodeJSON(w, r, &p); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+	if !cfg.webhookAllowPrivate {
+		if err := webhooks.CheckURL(r.Context(), net.DefaultResolver, p.URL); err != nil {
+			respondWithProblem(w, problem.Invalid("url", "private_address", "must not point at a local or private network address"))
+			return
+		}
+	}
+	if p.Events == nil {
+		p.Events = []string{}
+	}
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue creating webhook endpoint", err)
+		return
+	}
+	secret = "whsec_" + secret
+	endpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		ID:        uuid.New(),
+		UserID:    owner,
+		Url:       p.URL,
+		Secret:    secret,
+		Events:    p.Events,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue creating webhook endpoint", err)
+		return
+	}
+	cfg.auditAppWebhook(r, owner, auditEntry{
+		Action:     auditEndpointCreate,
+		TargetType: "webhook_endpoint",
+		TargetID:   endpoint.ID.String(),
+		Metadata:   map[string]any{"url": endpoint.Url, "events": endpoint.Events},
+	})
+	res := webhookEndpointFromRow(endpoint)
+	res.Secret = endpoint.Secret
+	respondWithJson(w, http.StatusCreated, res)
+}
+
+func (cfg *apiConfig) handlerListWebhookEndpoints(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoints, err := cfg.db.ListWebhookEndpoints(r.Context(), owner)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	res := []webhookEndpoint{}
+	for _, e := range endpoints {
+		res = append(res, webhookEndpointFromRow(e))
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+// ownedEndpoint loads the endpoint in the path if it belongs to owner,
+// writing a 404 otherwise.
+func (cfg *apiConfig) ownedEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.db.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: owner,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func (cfg *apiConfig) handlerGetWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.ownedEndpoint(w, r, owner)
+	if !ok {
+		return
+	}
+	respondWithJson(w, http.StatusOK, webhookEndpointFromRow(endpoint))
+}
+
+func (cfg *apiConfig) handlerDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	n, err := cfg.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: owner,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue deleting webhook endpoint", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), nil)
+		return
+	}
+	cfg.auditAppWebhook(r, owner, auditEntry{
+		Action:     auditEndpointDelete,
+		TargetType: "webhook_endpoint",
+		TargetID:   endpointID.String(),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type webhookDelivery struct {
+	ID            uuid.UUID         `json:"id"`
+	Event         string            `json:"event"`
+	Payload       json.RawMessage   `json:"payload"`
+	Status        string            `json:"status"`
+	Attempts      int32             `json:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at"`
+	LastError     string            `json:"last_error,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	DeliveredAt   *time.Time        `json:"delivered_at"`
+	History       []deliveryAttempt `json:"history,omitempty"`
+}
+
+type deliveryAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int32     `json:"status_code"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int32     `json:"duration_ms"`
+}
+
+func webhookDeliveryFromRow(d database.WebhookDelivery) webhookDelivery {
+	res := webhookDelivery{
+		ID:        d.ID,
+		Event:     d.Event,
+		Payload:   d.Payload,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		LastError: d.LastError,
+		CreatedAt: d.CreatedAt,
+	}
+	if d.Status == webhooks.StatusPending {
+		res.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.DeliveredAt.Valid {
+		res.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return res
+}
+
+func (cfg *apiConfig) handlerListWebhookDeliveries(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.ownedEndpoint(w, r, owner)
+	if !ok {
+		return
+	}
+	status := sql.NullString{}
+	if v := r.URL.Query().Get("status"); v != "" {
+		status = sql.NullString{String: v, Valid: true}
+	}
+	deliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Status:     status,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	res := []webhookDelivery{}
+	for _, d := range deliveries {
+		res = append(res, webhookDeliveryFromRow(d))
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) handlerGetWebhookDelivery(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.ownedEndpoint(w, r, owner)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	delivery, err := cfg.db.GetWebhookDelivery(r.Context(), database.GetWebhookDeliveryParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	attempts, err := cfg.db.ListWebhookDeliveryAttempts(r.Context(), delivery.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	res := webhookDeliveryFromRow(delivery)
+	res.History = []deliveryAttempt{}
+	for _, a := range attempts {
+		res.History = append(res.History, deliveryAttempt{
+			AttemptedAt: a.AttemptedAt,
+			StatusCode:  a.StatusCode,
+			Error:       a.Error,
+			DurationMs:  a.DurationMs,
+		})
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+// handlerRetryWebhookDelivery puts a delivery back in the queue to be sent
+// straight away with a fresh set of attempts. It is how dead deliveries are
+// brought back once the receiver is fixed.
+func (cfg *apiConfig) handlerRetryWebhookDelivery(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.ownedEndpoint(w, r, owner)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	delivery, err := cfg.db.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{
+		NextAttemptAt: time.Now().UTC(),
+		ID:            deliveryID,
+		EndpointID:    endpoint.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue retrying delivery", err)
+		return
+	}
+	cfg.auditAppWebhook(r, owner, auditEntry{
+		Action:     auditEndpointRetry,
+		TargetType: "webhook_delivery",
+		TargetID:   delivery.ID.String(),
+		Metadata:   map[string]any{"endpoint_id": endpoint.ID},
+	})
+	respondWithJson(w, http.StatusOK, webhookDeliveryFromRow(delivery))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookEndpointsMustBePublic(t *testing.T) {
+	s := loadSpec(t)
+	ts := newTestServer(t)
+	for _, url := range []string{
+		"http://127.0.0.1:6379/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.5/hook",
+		"http://[::1]:8080/hook",
+		"http://localhost/hook",
+	} {
+		t.Run(url, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/webhooks", strings.NewReader(`{"url":"`+url+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token(t, uuid.New()))
+			pattern, rec := ts.do(req)
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"code":"private_address"`) {
+				t.Fatalf("expected 400 private_address, got %d: %s", rec.Code, rec.Body)
+			}
+			s.checkResponse(t, pattern, rec)
+		})
+	}
+	if err := ts.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppWebhookChangesAreAudited(t *testing.T) {
+	s := loadSpec(t)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	moderator := database.User{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Email: "mod@example.com", IsModerator: true}
+	endpoint := database.WebhookEndpoint{ID: uuid.New(), Url: "https://example.com/hook", Secret: "whsec_x", Events: []string{}, Active: true, CreatedAt: now}
+	delivery := database.WebhookDelivery{ID: uuid.New(), EndpointID: endpoint.ID, Event: eventChirpCreated, Payload: []byte("{}"), Status: "pending", NextAttemptAt: now, CreatedAt: now}
+	base := "/admin/webhooks/endpoints/" + endpoint.ID.String()
+
+	testCases := []struct {
+		Name       string
+		Method     string
+		Path       string
+		Body       string
+		Expect     func(mock sqlmock.Sqlmock)
+		Action     string
+		TargetType string
+		TargetID   string
+		Code       int
+	}{
+		{
+			Name: "create", Method: "POST", Path: "/admin/webhooks/endpoints", Body: `{"url":"https://example.com/hook"}`,
+			Expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("CreateWebhookEndpoint").WillReturnRows(rows(endpoint))
+			},
+			Action: auditEndpointCreate, TargetType: "webhook_endpoint", TargetID: endpoint.ID.String(), Code: http.StatusCreated,
+		},
+		{
+			Name: "delete", Method: "DELETE", Path: base,
+			Expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DeleteWebhookEndpoint").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			Action: auditEndpointDelete, TargetType: "webhook_endpoint", TargetID: endpoint.ID.String(), Code: http.StatusNoContent,
+		},
+		{
+			Name: "retry", Method: "POST", Path: base + "/deliveries/" + delivery.ID.String() + "/retry",
+			Expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("GetWebhookEndpoint").WillReturnRows(rows(endpoint))
+				mock.ExpectQuery("RetryWebhookDelivery").WillReturnRows(rows(delivery))
+			},
+			Action: auditEndpointRetry, TargetType: "webhook_delivery", TargetID: delivery.ID.String(), Code: http.StatusOK,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.Name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.cfg.webhookAllowPrivate = true
+			ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(moderator))
+			c.Expect(ts.mock)
+			ts.mock.ExpectExec("CreateAuditEvent").
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), c.Action, moderator.ID.String(), c.TargetType, c.TargetID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			req := httptest.NewRequest(c.Method, c.Path, strings.NewReader(c.Body))
+			if c.Body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			req.Header.Set("Authorization", "Bearer "+token(t, moderator.ID))
+			pattern, rec := ts.do(req)
+			if rec.Code != c.Code {
+				t.Fatalf("expected %d, got %d: %s", c.Code, rec.Code, rec.Body)
+			}
+			s.checkResponse(t, pattern, rec)
+			if err := ts.mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
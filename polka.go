@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	polkaSignatureTolerance = 5 * time.Minute
+	maxWebhookBodyBytes     = 1 << 20
+	// webhookClaimLease is how long a claimed event is left to the request
+	// that claimed it. After that a retry may take it over, so an event whose
+	// request died part way through is not stuck in processing.
+	webhookClaimLease = time.Minute
+)
+
+// Webhook event statuses.
+const (
+	webhookProcessing = "processing"
+	webhookProcessed  = "processed"
+	webhookIgnored    = "ignored"
+	webhookFailed     = "failed"
+)
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID    uuid.UUID  `json:"user_id"`
+		Plan      string     `json:"plan"`
+		PeriodEnd *time.Time `json:"period_end"`
+	} `json:"data"`
+}
+
+// authenticatePolka checks a delivery is really from Polka. With
+// POLKA_WEBHOOK_SECRET set the Polka-Signature header must carry a valid,
+// recent HMAC of the body; otherwise the older static ApiKey header is
+// accepted.
+func (cfg *apiConfig) authenticatePolka(r *http.Request, body []byte) error {
+	if cfg.polkaSecret != "" {
+		return auth.VerifySignature(r.Header.Get("Polka-Signature"), cfg.polkaSecret, body, polkaSignatureTolerance, time.Now())
+	}
+	providedKey, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		return err
+	}
+	if providedKey != cfg.apiKey {
+		return errors.New("api key does not match")
+	}
+	return nil
+}
+
+// polkaDeliveryID identifies a delivery so retries can be recognised. With
+// signed deliveries it must come from the body, which the signature covers:
+// the event's id, or else a hash of the body. Otherwise a captured delivery
+// could be replayed under a new ID and be applied again. The
+// Polka-Delivery-Id header is only trusted with the static API key, which
+// covers nothing in the request anyway.
+func (cfg *apiConfig) polkaDeliveryID(r *http.Request, body []byte, p polkaEvent) string {
+	if p.ID != "" {
+		return p.ID
+	}
+	if cfg.polkaSecret == "" {
+		if id := r.Header.Get("Polka-Delivery-Id"); id != "" {
+			return id
+		}
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "body too large", err)
+		return
+	}
+	if err := cfg.authenticatePolka(r, body); err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	p := polkaEvent{}
+	if err := json.Unmarshal(body, &p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "issue decoding body", err)
+		return
+	}
+
+	deliveryID := cfg.polkaDeliveryID(r, body, p)
+	_, err = cfg.db.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		ID:         uuid.New(),
+		DeliveryID: deliveryID,
+		Source:     "polka",
+		Event:      p.Event,
+		Payload:    body,
+		ReceivedAt: time.Now(),
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "issue storing webhook event", err)
+		return
+	}
+
+	// Claiming moves a new, failed or abandoned event to processing in one
+	// statement, so of several concurrent deliveries only one applies it.
+	now := time.Now()
+	event, err := cfg.db.ClaimWebhookEvent(r.Context(), database.ClaimWebhookEventParams{
+		ClaimedAt:   now,
+		DeliveryID:  deliveryID,
+		StaleBefore: now.Add(-webhookClaimLease),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		event, err = cfg.db.GetWebhookEventByDeliveryID(r.Context(), deliveryID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "issue loading webhook event", err)
+			return
+		}
+		if event.Status == webhookProcessing {
+			// Ask Polka to retry, in case the other attempt fails.
+			respondWithError(w, http.StatusConflict, "delivery is already being processed", nil)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue claiming webhook event", err)
+		return
+	}
+
+	event, err = cfg.processWebhookEvent(r, event)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue storing webhook event", err)
+		return
+	}
+	if event.Status == webhookFailed {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), errors.New(event.Error))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// processWebhookEvent applies a stored event and records the outcome on it.
+// The returned error is only about recording; a failure to apply the event is
+// reported through the event's status. It carries on if the caller hangs up,
+// so a claimed event is not left half applied.
+func (cfg *apiConfig) processWebhookEvent(r *http.Request, event database.WebhookEvent) (database.WebhookEvent, error) {
+	r = r.WithContext(context.WithoutCancel(r.Context()))
+	status, applyErr := cfg.applyPolkaEvent(r, event.Payload)
+	errText := ""
+	if applyErr != nil {
+		status = webhookFailed
+		errText = applyErr.Error()
+	}
+	cfg.metrics.webhookEvents.WithLabelValues(event.Source, event.Event, status).Inc()
+	return cfg.db.UpdateWebhookEventStatus(r.Context(), database.UpdateWebhookEventStatusParams{
+		Status:      status,
+		Error:       errText,
+		ProcessedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:          event.ID,
+	})
+}
+
+func (cfg *apiConfig) applyPolkaEvent(r *http.Request, payload []byte) (string, error) {
+	p := polkaEvent{}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "", err
+	}
+	if !isSubscriptionEvent(p.Event) {
+		return webhookIgnored, nil
+	}
+	if err := cfg.applySubscriptionEvent(r.Context(), p); err != nil {
+		return "", err
+	}
+	action := auditPolkaSubscription
+	if p.Event == polkaUserUpgraded {
+		action = auditPolkaUpgrade
+	}
+	cfg.audit(r, auditEntry{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   p.Data.UserID.String(),
+		Metadata:   map[string]any{"event": p.Event},
+	})
+	return webhookProcessed, nil
+}
+
+type webhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	DeliveryID  string          `json:"delivery_id"`
+	Source      string          `json:"source"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int32           `json:"attempts"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func webhookEventFromRow(e database.WebhookEvent) webhookEvent {
+	ev := webhookEvent{
+		ID:         e.ID,
+		DeliveryID: e.DeliveryID,
+		Source:     e.Source,
+		Event:      e.Event,
+		Payload:    e.Payload,
+		Status:     e.Status,
+		Error:      e.Error,
+		Attempts:   e.Attempts,
+		ReceivedAt: e.ReceivedAt,
+	}
+	if e.ProcessedAt.Valid {
+		ev.ProcessedAt = &e.ProcessedAt.Time
+	}
+	return ev
+}
+
+func (cfg *apiConfig) handlerListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireModerator(w, r); !ok {
+		return
+	}
+	status := sql.NullString{}
+	if v := r.URL.Query().Get("status"); v != "" {
+		status = sql.NullString{String: v, Valid: true}
+	}
+	events, err := cfg.db.ListWebhookEvents(r.Context(), status)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	res := []webhookEvent{}
+	for _, e := range events {
+		res = append(res, webhookEventFromRow(e))
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) handlerGetWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireModerator(w, r); !ok {
+		return
+	}
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	event, err := cfg.db.GetWebhookEventByID(r.Context(), eventID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, webhookEventFromRow(event))
+}
+
+// handlerReplayWebhookEvent processes a stored event again, whatever its
+// current status. A renewal that was already applied is ignored, but an
+// upgrade without a period_end starts a fresh period from now.
+func (cfg *apiConfig) handlerReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	event, err := cfg.db.GetWebhookEventByID(r.Context(), eventID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	event, err = cfg.processWebhookEvent(r, event)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue storing webhook event", err)
+		return
+	}
+	cfg.audit(r, auditEntry{
+		Action:     auditWebhookReplay,
+		Actor:      actor(moderator.ID),
+		TargetType: "webhook_event",
+		TargetID:   event.ID.String(),
+		Metadata:   map[string]any{"status": event.Status},
+	})
+	respondWithJson(w, http.StatusOK, webhookEventFromRow(event))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recent matches a time argument about ago before now.
+type recent struct {
+	ago time.Duration
+}
+
+func (m recent) Match(v driver.Value) bool {
+	at, ok := v.(time.Time)
+	return ok && time.Since(at.Add(m.ago)).Abs() < 5*time.Second
+}
+
+func TestPolkaWebhookClaimsDeliveries(t *testing.T) {
+	s := loadSpec(t)
+	const body = `{"event":"chirp.liked","data":{}}`
+	stored := database.WebhookEvent{
+		ID:         uuid.New(),
+		DeliveryID: "delivery-1",
+		Source:     "polka",
+		Event:      "chirp.liked",
+		Payload:    []byte(body),
+		ReceivedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	with := func(status string) database.WebhookEvent {
+		e := stored
+		e.Status = status
+		return e
+	}
+
+	deliver := func(t *testing.T, ts *testServer) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey polka-key")
+		req.Header.Set("Polka-Delivery-Id", stored.DeliveryID)
+		pattern, rec := ts.do(req)
+		s.checkResponse(t, pattern, rec)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	t.Run("repeat of a delivery that never finished is processed", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("CreateWebhookEvent").WillReturnRows(emptyRows(database.WebhookEvent{}))
+		ts.mock.ExpectQuery("ClaimWebhookEvent").
+			WithArgs(sqlmock.AnyArg(), stored.DeliveryID, sqlmock.AnyArg()).
+			WillReturnRows(rows(with(webhookProcessing)))
+		ts.mock.ExpectQuery("UpdateWebhookEventStatus").WillReturnRows(rows(with(webhookIgnored)))
+		if rec := deliver(t, ts); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("takes over a claim whose lease ran out", func(t *testing.T) {
+		// A row left in processing by a request that died is claimable
+		// again once it was claimed more than webhookClaimLease ago.
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("CreateWebhookEvent").WillReturnRows(emptyRows(database.WebhookEvent{}))
+		ts.mock.ExpectQuery("ClaimWebhookEvent").
+			WithArgs(recent{}, stored.DeliveryID, recent{ago: webhookClaimLease}).
+			WillReturnRows(rows(with(webhookProcessing)))
+		ts.mock.ExpectQuery("UpdateWebhookEventStatus").WillReturnRows(rows(with(webhookIgnored)))
+		if rec := deliver(t, ts); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("repeat while another attempt is processing", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("CreateWebhookEvent").WillReturnRows(emptyRows(database.WebhookEvent{}))
+		ts.mock.ExpectQuery("ClaimWebhookEvent").WillReturnRows(emptyRows(database.WebhookEvent{}))
+		ts.mock.ExpectQuery("GetWebhookEventByDeliveryID").WillReturnRows(rows(with(webhookProcessing)))
+		if rec := deliver(t, ts); rec.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("repeat of a processed delivery", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("CreateWebhookEvent").WillReturnRows(emptyRows(database.WebhookEvent{}))
+		ts.mock.ExpectQuery("ClaimWebhookEvent").WillReturnRows(emptyRows(database.WebhookEvent{}))
+		ts.mock.ExpectQuery("GetWebhookEventByDeliveryID").WillReturnRows(rows(with(webhookProcessed)))
+		if rec := deliver(t, ts); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestPolkaDeliveryKeyComesFromSignedBody(t *testing.T) {
+	const secret = "whsec_test"
+	hash := func(body string) string {
+		sum := sha256.Sum256([]byte(body))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	testCases := []struct {
+		Name   string
+		Body   string
+		Secret string
+		Key    string
+	}{
+		{"signed with an event id", `{"id":"evt_1","event":"chirp.liked","data":{}}`, secret, "evt_1"},
+		{"signed without an event id", `{"event":"chirp.liked","data":{}}`, secret, hash(`{"event":"chirp.liked","data":{}}`)},
+		{"api key", `{"event":"chirp.liked","data":{}}`, "", "replayed-id"},
+	}
+	for _, c := range testCases {
+		t.Run(c.Name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.cfg.polkaSecret = c.Secret
+			ts.mock.ExpectQuery("CreateWebhookEvent").
+				WithArgs(sqlmock.AnyArg(), c.Key, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(emptyRows(database.WebhookEvent{}))
+			ts.mock.ExpectQuery("ClaimWebhookEvent").WillReturnRows(emptyRows(database.WebhookEvent{}))
+			ts.mock.ExpectQuery("GetWebhookEventByDeliveryID").
+				WillReturnRows(rows(database.WebhookEvent{ID: uuid.New(), DeliveryID: c.Key, Payload: []byte(c.Body), Status: webhookProcessed}))
+
+			req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(c.Body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Polka-Delivery-Id", "replayed-id")
+			if c.Secret != "" {
+				req.Header.Set("Polka-Signature", auth.SignPayload(c.Secret, []byte(c.Body), time.Now()))
+			} else {
+				req.Header.Set("Authorization", "ApiKey polka-key")
+			}
+			if _, rec := ts.do(req); rec.Code != http.StatusNoContent {
+				t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+			}
+			if err := ts.mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
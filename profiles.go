@@ -0,0 +1,219 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"github.com/MattInReality/Chirpy/internal/validate"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+func init() {
+	validate.Register("handle", "must be 3-30 letters, numbers or underscores", func(s string) bool {
+		h := normalizeHandle(s)
+		return h == "" || handlePattern.MatchString(h)
+	})
+}
+
+// normalizeHandle lower-cases a handle so lookups are case-insensitive. A
+// blank handle clears it.
+func normalizeHandle(h string) string {
+	return strings.ToLower(strings.TrimSpace(h))
+}
+
+type publicProfile struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Handle         string    `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	Location       string    `json:"location"`
+	Website        string    `json:"website"`
+	AvatarURL      string    `json:"avatar_url"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ChirpCount     int64     `json:"chirp_count"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+}
+
+func profileFromRow(p database.GetPublicProfileByIDRow) publicProfile {
+	return publicProfile{
+		ID:             p.ID,
+		CreatedAt:      p.CreatedAt,
+		Handle:         p.Handle.String,
+		DisplayName:    p.DisplayName,
+		Bio:            p.Bio,
+		Location:       p.Location,
+		Website:        p.Website,
+		AvatarURL:      p.AvatarUrl,
+		IsChirpyRed:    p.IsChirpyRed,
+		ChirpCount:     p.ChirpCount,
+		FollowerCount:  p.FollowerCount,
+		FollowingCount: p.FollowingCount,
+	}
+}
+
+func (cfg *apiConfig) handlerGetProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	p, err := cfg.db.GetPublicProfileByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, profileFromRow(p))
+}
+
+func (cfg *apiConfig) handlerGetProfileByHandle(w http.ResponseWriter, r *http.Request) {
+	handle := strings.ToLower(r.PathValue("handle"))
+	p, err := cfg.db.GetPublicProfileByHandle(r.Context(), handle)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, profileFromRow(database.GetPublicProfileByIDRow(p)))
+}
+
+func (cfg *apiConfig) handlerUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	type params struct {
+		Handle      string `json:"handle" validate:"handle"`
+		DisplayName string `json:"display_name" validate:"max=50"`
+		Bio         string `json:"bio" validate:"max=160"`
+		Location    string `json:"location" validate:"max=30"`
+		Website     string `json:"website" validate:"max=200,url"`
+		AvatarURL   string `json:"avatar_url" validate:"max=200,url"`
+	}
+	p := params{}
+	if err := cfg.decodeJSON(w, r, &p); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+	p.Handle = normalizeHandle(p.Handle)
+	_, err = cfg.db.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		Handle:      sql.NullString{String: p.Handle, Valid: p.Handle != ""},
+		DisplayName: p.DisplayName,
+		Bio:         p.Bio,
+		Location:    p.Location,
+		Website:     p.Website,
+		AvatarUrl:   p.AvatarURL,
+		UpdatedAt:   time.Now(),
+		ID:          userID,
+	})
+	if isUniqueViolation(err) {
+		respondWithProblem(w, problem.New(http.StatusConflict, "handle_taken", "handle is already taken", err))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue updating profile", err)
+		return
+	}
+	profile, err := cfg.db.GetPublicProfileByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue fetching profile", err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, profileFromRow(profile))
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	if followeeID == userID {
+		respondWithError(w, http.StatusBadRequest, "you cannot follow yourself", nil)
+		return
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), followeeID); err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	blocked, err := cfg.db.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		UserA: userID,
+		UserB: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue following user", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "you cannot follow this user", nil)
+		return
+	}
+	err = cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue following user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	err = cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue unfollowing user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isUniqueViolation reports whether err is a postgres unique constraint failure.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	s := loadSpec(t)
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	user := database.User{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "mo@example.com", Handle: sql.NullString{String: "mo_smith", Valid: true}}
+	profile := database.GetPublicProfileByIDRow{ID: userID, CreatedAt: now, Handle: user.Handle}
+
+	update := func(t *testing.T, ts *testServer, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("PUT", "/api/users/profile", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token(t, userID))
+		pattern, rec := ts.do(req)
+		s.checkResponse(t, pattern, rec)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	t.Run("normalizes the handle", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("UpdateUserProfile").
+			WithArgs("mo_smith", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), userID).
+			WillReturnRows(rows(user))
+		ts.mock.ExpectQuery("GetPublicProfileByID").WillReturnRows(rows(profile))
+		rec := update(t, ts, `{"handle":"  Mo_Smith "}`)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"handle":"mo_smith"`) {
+			t.Fatalf("expected 200 with the lower-cased handle, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("a blank handle clears it", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("UpdateUserProfile").
+			WithArgs(nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), userID).
+			WillReturnRows(rows(user))
+		ts.mock.ExpectQuery("GetPublicProfileByID").WillReturnRows(rows(profile))
+		if rec := update(t, ts, `{"handle":"  "}`); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	for _, handle := range []string{"mo", "mo smith", "mo-smith", strings.Repeat("a", 31)} {
+		t.Run("rejects "+handle, func(t *testing.T) {
+			ts := newTestServer(t)
+			rec := update(t, ts, `{"handle":"`+handle+`"}`)
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"handle"`) {
+				t.Fatalf("expected 400 on handle, got %d: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+
+	t.Run("handle taken", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("UpdateUserProfile").WillReturnError(&pq.Error{Code: "23505"})
+		rec := update(t, ts, `{"handle":"mo_smith"}`)
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"code":"handle_taken"`) {
+			t.Fatalf("expected 409 handle_taken, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestGetProfile(t *testing.T) {
+	s := loadSpec(t)
+	ts := newTestServer(t)
+	ts.mock.ExpectQuery("GetPublicProfileByID").WillReturnRows(emptyRows(database.GetPublicProfileByIDRow{}))
+	pattern, rec := ts.do(httptest.NewRequest("GET", "/api/users/"+uuid.New().String(), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body)
+	}
+	s.checkResponse(t, pattern, rec)
+	if err := ts.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollowUser(t *testing.T) {
+	s := loadSpec(t)
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	other := database.User{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Email: "other@example.com"}
+
+	follow := func(t *testing.T, ts *testServer, followee uuid.UUID) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/users/"+followee.String()+"/follow", nil)
+		req.Header.Set("Authorization", "Bearer "+token(t, userID))
+		pattern, rec := ts.do(req)
+		s.checkResponse(t, pattern, rec)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	t.Run("follows", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(other))
+		ts.mock.ExpectQuery("IsBlockedBetween").WithArgs(userID, other.ID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		ts.mock.ExpectExec("FollowUser").WithArgs(userID, other.ID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		if rec := follow(t, ts, other.ID); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("yourself", func(t *testing.T) {
+		ts := newTestServer(t)
+		if rec := follow(t, ts, userID); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("someone who does not exist", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnRows(emptyRows(database.User{}))
+		if rec := follow(t, ts, other.ID); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("across a block", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(other))
+		ts.mock.ExpectQuery("IsBlockedBetween").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		if rec := follow(t, ts, other.ID); rec.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
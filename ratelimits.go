@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/config"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/ratelimit"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// rateLimitPolicy sets the limits for a route. Callers are identified by API
+// key, user or client IP, and each kind of caller has its own limit. The user
+// limit is scaled by the user's tier. A zero limit means that kind of caller
+// is not limited by the policy.
+type rateLimitPolicy struct {
+	name   string
+	ip     ratelimit.Limit
+	user   ratelimit.Limit
+	apiKey ratelimit.Limit
+}
+
+var (
+	// globalPolicy applies to every request on top of any route policy.
+	globalPolicy = rateLimitPolicy{
+		name:   "global",
+		ip:     ratelimit.Limit{Requests: 120, Window: time.Minute},
+		user:   ratelimit.Limit{Requests: 300, Window: time.Minute},
+		apiKey: ratelimit.Limit{Requests: 600, Window: time.Minute},
+	}
+	signupPolicy = rateLimitPolicy{
+		name: "signup",
+		ip:   ratelimit.Limit{Requests: 5, Window: time.Hour},
+		user: ratelimit.Limit{Requests: 5, Window: time.Hour},
+	}
+	loginPolicy = rateLimitPolicy{
+		name: "login",
+		ip:   ratelimit.Limit{Requests: 10, Window: time.Minute},
+		user: ratelimit.Limit{Requests: 10, Window: time.Minute},
+	}
+	createChirpPolicy = rateLimitPolicy{
+		name: "create_chirp",
+		ip:   ratelimit.Limit{Requests: 10, Window: time.Minute},
+		user: ratelimit.Limit{Requests: 60, Window: time.Hour},
+	}
+	webhookPolicy = rateLimitPolicy{
+		name:   "webhook",
+		ip:     ratelimit.Limit{Requests: 30, Window: time.Minute},
+		apiKey: ratelimit.Limit{Requests: 600, Window: time.Minute},
+	}
+)
+
+func newRateLimitStore(conf *config.Config, conn *sql.DB, db *database.Queries) ratelimit.Store {
+	if conf.RateLimitStore == "postgres" {
+		return ratelimit.NewPostgresStore(conn, db)
+	}
+	return ratelimit.NewMemoryStore()
+}
+
+// rateLimitKey identifies the caller and picks the limit that applies to
+// them. Only credentials that check out count, so a client cannot get a fresh
+// bucket by sending made up tokens.
+func (cfg *apiConfig) rateLimitKey(r *http.Request, p rateLimitPolicy) (string, ratelimit.Limit) {
+	if key, err := auth.GetAPIKey(r.Header); err == nil && cfg.apiKey != "" && key == cfg.apiKey {
+		sum := sha256.Sum256([]byte(key))
+		return p.name + ":key:" + hex.EncodeToString(sum[:8]), p.apiKey
+	}
+	if viewer := cfg.viewerID(r); viewer.Valid {
+		if _, limits, err := cfg.limitsFor(r.Context(), viewer.UUID); err == nil {
+			limit := p.user
+			limit.Requests *= limits.RateLimitMultiplier
+			return p.name + ":user:" + viewer.UUID.String(), limit
+		}
+	}
+	return p.name + ":ip:" + cfg.clientIP(r), p.ip
+}
+
+// clientIP returns the address of the caller. When TRUST_PROXY_HEADERS is set
+// the last X-Forwarded-For entry is used, which is the one added by the proxy
+// in front of us.
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	if cfg.trustProxyHeaders {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			parts := strings.Split(fwd, ",")
+			return strings.TrimSpace(parts[len(parts)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (cfg *apiConfig) middlewareRateLimit(p rateLimitPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, limit := cfg.rateLimitKey(r, p)
+		if limit.Requests == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		res, err := cfg.limiter.Take(r.Context(), key, limit, time.Now())
+		if err != nil {
+			// Fail open: an unavailable store should not take the API down.
+			logger(r.Context()).ErrorContext(r.Context(), "rate limiter unavailable", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		ratelimit.WriteHeaders(w.Header(), res)
+		if !res.Allowed {
+			respondWithError(w, http.StatusTooManyRequests, "rate limit exceeded, please slow down", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"github.com/MattInReality/Chirpy/internal/validate"
+	"github.com/google/uuid"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var reportReasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"violence":       true,
+	"sexual":         true,
+	"misinformation": true,
+	"other":          true,
+}
+
+func init() {
+	validate.Register("report_reason", "is not a known report reason", func(s string) bool { return reportReasons[s] })
+}
+
+// Moderator actions that can resolve the open reports on a chirp.
+const (
+	actionDismiss       = "dismiss"
+	actionHideChirp     = "hide_chirp"
+	actionDeleteChirp   = "delete_chirp"
+	actionSuspendAuthor = "suspend_author"
+)
+
+type report struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ChirpID        uuid.UUID  `json:"chirp_id"`
+	ReporterID     uuid.UUID  `json:"reporter_id"`
+	Reason         string     `json:"reason"`
+	Details        string     `json:"details"`
+	Status         string     `json:"status"`
+	Resolution     string     `json:"resolution,omitempty"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	ResolvedBy     *uuid.UUID `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+}
+
+func reportFromRow(r database.ChirpReport) report {
+	rep := report{
+		ID:             r.ID,
+		CreatedAt:      r.CreatedAt,
+		ChirpID:        r.ChirpID,
+		ReporterID:     r.ReporterID,
+		Reason:         r.Reason,
+		Details:        r.Details,
+		Status:         r.Status,
+		Resolution:     r.Resolution.String,
+		ResolutionNote: r.ResolutionNote,
+	}
+	if r.ResolvedBy.Valid {
+		rep.ResolvedBy = &r.ResolvedBy.UUID
+	}
+	if r.ResolvedAt.Valid {
+		rep.ResolvedAt = &r.ResolvedAt.Time
+	}
+	return rep
+}
+
+func (cfg *apiConfig) handlerReportChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	type params struct {
+		Reason  string `json:"reason" validate:"required,report_reason"`
+		Details string `json:"details" validate:"max=500"`
+	}
+	p := params{}
+	if err := cfg.decodeJSON(w, r, &p); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+	c, err := cfg.db.GetChirpByID(r.Context(), database.GetChirpByIDParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	if c.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "you cannot report your own chirp", nil)
+		return
+	}
+	now := time.Now()
+	created, err := cfg.db.CreateChirpReport(r.Context(), database.CreateChirpReportParams{
+		ID:         uuid.New(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		ChirpID:    chirpID,
+		ReporterID: userID,
+		Reason:     p.Reason,
+		Details:    p.Details,
+	})
+	if isUniqueViolation(err) {
+		respondWithProblem(w, problem.New(http.StatusConflict, "already_reported", "you have already reported this chirp", err))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue inserting in to database", err)
+		return
+	}
+	respondWithJson(w, http.StatusCreated, reportFromRow(created))
+}
+
+func (cfg *apiConfig) handlerListReports(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireModerator(w, r); !ok {
+		return
+	}
+	open, err := cfg.db.GetOpenReports(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	byChirp := map[uuid.UUID][]report{}
+	ids := []uuid.UUID{}
+	for _, rep := range open {
+		if _, seen := byChirp[rep.ChirpID]; !seen {
+			ids = append(ids, rep.ChirpID)
+		}
+		byChirp[rep.ChirpID] = append(byChirp[rep.ChirpID], reportFromRow(rep))
+	}
+	chirps, err := cfg.db.GetChirpsByIDsIncludingDeleted(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error getting data from database", err)
+		return
+	}
+	type queueEntry struct {
+		Chirp       moderatorChirp `json:"chirp"`
+		ReportCount int            `json:"report_count"`
+		Reasons     map[string]int `json:"reasons"`
+		Reports     []report       `json:"reports"`
+	}
+	queue := []queueEntry{}
+	for _, c := range chirps {
+		reports := byChirp[c.ID]
+		reasons := map[string]int{}
+		for _, rep := range reports {
+			reasons[rep.Reason]++
+		}
+		queue = append(queue, queueEntry{
+			Chirp:       moderatorChirpFromRow(c),
+			ReportCount: len(reports),
+			Reasons:     reasons,
+			Reports:     reports,
+		})
+	}
+	// Most reported first, then whichever has been waiting longest.
+	sort.Slice(queue, func(i, j int) bool {
+		if queue[i].ReportCount != queue[j].ReportCount {
+			return queue[i].ReportCount > queue[j].ReportCount
+		}
+		return queue[i].Reports[0].CreatedAt.Before(queue[j].Reports[0].CreatedAt)
+	})
+	respondWithJson(w, http.StatusOK, queue)
+}
+
+func (cfg *apiConfig) handlerResolveReports(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	type params struct {
+		Action string `json:"action" validate:"required,oneof=dismiss hide_chirp delete_chirp suspend_author"`
+		Note   string `json:"note"`
+	}
+	p := params{}
+	if err := cfg.decodeJSON(w, r, &p); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+	c, err := cfg.db.GetChirpByIDIncludingDeleted(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue resolving reports", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	now := time.Now()
+	if err := applyModerationAction(r.Context(), qtx, p.Action, c, now); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue applying moderation action", err)
+		return
+	}
+	resolved, err := qtx.ResolveChirpReports(r.Context(), database.ResolveChirpReportsParams{
+		Resolution:     sql.NullString{String: p.Action, Valid: true},
+		ResolutionNote: p.Note,
+		ResolvedBy:     uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		ResolvedAt:     sql.NullTime{Time: now, Valid: true},
+		ChirpID:        chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue resolving reports", err)
+		return
+	}
+	if len(resolved) == 0 {
+		respondWithError(w, http.StatusNotFound, "no open reports for this chirp", nil)
+		return
+	}
+	for _, rep := range resolved {
+		if err := notify(r.Context(), qtx, rep.ReporterID, "report_resolved", reportOutcomeMessage(p.Action)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "issue notifying reporters", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue resolving reports", err)
+		return
+	}
+	cfg.audit(r, auditEntry{
+		Action:     auditReportResolve,
+		Actor:      actor(moderator.ID),
+		TargetType: "chirp",
+		TargetID:   chirpID.String(),
+		Metadata:   map[string]any{"action": p.Action, "note": p.Note, "reports": len(resolved)},
+	})
+	res := []report{}
+	for _, rep := range resolved {
+		res = append(res, reportFromRow(rep))
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+func applyModerationAction(ctx context.Context, q *database.Queries, action string, c database.Chirp, now time.Time) error {
+	at := sql.NullTime{Time: now, Valid: true}
+	switch action {
+	case actionHideChirp:
+		return q.HideChirp(ctx, database.HideChirpParams{HiddenAt: at, ID: c.ID})
+	case actionDeleteChirp:
+		if err := q.ModeratorDeleteChirp(ctx, database.ModeratorDeleteChirpParams{DeletedAt: at, ID: c.ID}); err != nil {
+			return err
+		}
+		return publish(ctx, q, eventChirpDeleted, c.UserID, chirpDeleted{ID: c.ID, UserID: c.UserID, DeletedAt: now})
+	case actionSuspendAuthor:
+		if err := q.SuspendUser(ctx, database.SuspendUserParams{SuspendedAt: at, ID: c.UserID}); err != nil {
+			return err
+		}
+		return q.RevokeUserRefreshTokens(ctx, database.RevokeUserRefreshTokensParams{
+			RevokedAt: at,
+			UpdatedAt: now,
+			UserID:    c.UserID,
+		})
+	}
+	return nil
+}
+
+func reportOutcomeMessage(action string) string {
+	outcome := "no action was needed"
+	switch action {
+	case actionHideChirp:
+		outcome = "the chirp has been hidden"
+	case actionDeleteChirp:
+		outcome = "the chirp has been removed"
+	case actionSuspendAuthor:
+		outcome = "the author has been suspended"
+	}
+	return fmt.Sprintf("Thanks for your report. A moderator reviewed it and %s.", outcome)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/MattInReality/Chirpy/internal/health"
+	"net/http"
+)
+
+// routes registers every endpoint with handle, which is normally a
+// ServeMux's Handle. Every route here must be described in api/openapi.json;
+// the contract test fails when the two disagree.
+func (cfg *apiConfig) routes(handle func(pattern string, handler http.Handler), filepathRoot string, checks *health.Registry) {
+	handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app/", http.FileServer(http.Dir(filepathRoot)))))
+	handle("GET /api/healthz", checks.ReadyHandler())
+	handle("GET /api/readyz", checks.ReadyHandler())
+	handle("GET /api/livez", health.LiveHandler())
+	handle("GET /admin/metrics", http.HandlerFunc(cfg.getMetrics))
+	handle("GET /metrics", cfg.metrics.handler())
+	handle("GET /api/openapi.json", http.HandlerFunc(handlerOpenAPI))
+	handle("GET /api/docs", http.HandlerFunc(handlerDocs))
+	handle("POST /api/users", cfg.middlewareRateLimit(signupPolicy, http.HandlerFunc(cfg.handlerCreateUser)))
+	handle("PUT /api/users", http.HandlerFunc(cfg.handlerUpdateUser))
+	handle("PATCH /api/users", http.HandlerFunc(cfg.handlerUpdateUser))
+	handle("PUT /api/users/profile", http.HandlerFunc(cfg.handlerUpdateProfile))
+	handle("GET /api/users/subscription", http.HandlerFunc(cfg.handlerGetSubscription))
+	handle("GET /api/users/entitlements", http.HandlerFunc(cfg.handlerGetEntitlements))
+	handle("POST /api/webhooks", http.HandlerFunc(cfg.userWebhooks(cfg.handlerCreateWebhookEndpoint)))
+	handle("GET /api/webhooks", http.HandlerFunc(cfg.userWebhooks(cfg.handlerListWebhookEndpoints)))
+	handle("GET /api/webhooks/{endpointID}", http.HandlerFunc(cfg.userWebhooks(cfg.handlerGetWebhookEndpoint)))
+	handle("DELETE /api/webhooks/{endpointID}", http.HandlerFunc(cfg.userWebhooks(cfg.handlerDeleteWebhookEndpoint)))
+	handle("GET /api/webhooks/{endpointID}/deliveries", http.HandlerFunc(cfg.userWebhooks(cfg.handlerListWebhookDeliveries)))
+	handle("GET /api/webhooks/{endpointID}/deliveries/{deliveryID}", http.HandlerFunc(cfg.userWebhooks(cfg.handlerGetWebhookDelivery)))
+	handle("POST /api/webhooks/{endpointID}/deliveries/{deliveryID}/retry", http.HandlerFunc(cfg.userWebhooks(cfg.handlerRetryWebhookDelivery)))
+	handle("GET /api/users/{userID}", http.HandlerFunc(cfg.handlerGetProfile))
+	handle("GET /api/users/by-handle/{handle}", http.HandlerFunc(cfg.handlerGetProfileByHandle))
+	handle("POST /api/users/{userID}/follow", http.HandlerFunc(cfg.handlerFollowUser))
+	handle("DELETE /api/users/{userID}/follow", http.HandlerFunc(cfg.handlerUnfollowUser))
+	handle("POST /api/users/{userID}/block", http.HandlerFunc(cfg.handlerBlockUser))
+	handle("DELETE /api/users/{userID}/block", http.HandlerFunc(cfg.handlerUnblockUser))
+	handle("POST /api/users/{userID}/mute", http.HandlerFunc(cfg.handlerMuteUser))
+	handle("DELETE /api/users/{userID}/mute", http.HandlerFunc(cfg.handlerUnmuteUser))
+	handle("POST /admin/reset", http.HandlerFunc(cfg.handlerReset))
+	handle("GET /admin/chirps/deleted", http.HandlerFunc(cfg.handlerModeratorDeletedChirps))
+	handle("GET /admin/chirps/{chirpID}", http.HandlerFunc(cfg.handlerModeratorGetChirp))
+	handle("GET /admin/reports", http.HandlerFunc(cfg.handlerListReports))
+	handle("GET /admin/audit", http.HandlerFunc(cfg.handlerListAuditEvents))
+	handle("GET /admin/audit/export", http.HandlerFunc(cfg.handlerExportAuditEvents))
+	handle("GET /admin/webhooks/events", http.HandlerFunc(cfg.handlerListWebhookEvents))
+	handle("GET /admin/webhooks/events/{eventID}", http.HandlerFunc(cfg.handlerGetWebhookEvent))
+	handle("POST /admin/webhooks/events/{eventID}/replay", http.HandlerFunc(cfg.handlerReplayWebhookEvent))
+	handle("POST /admin/webhooks/endpoints", http.HandlerFunc(cfg.appWebhooks(cfg.handlerCreateWebhookEndpoint)))
+	handle("GET /admin/webhooks/endpoints", http.HandlerFunc(cfg.appWebhooks(cfg.handlerListWebhookEndpoints)))
+	handle("GET /admin/webhooks/endpoints/{endpointID}", http.HandlerFunc(cfg.appWebhooks(cfg.handlerGetWebhookEndpoint)))
+	handle("DELETE /admin/webhooks/endpoints/{endpointID}", http.HandlerFunc(cfg.appWebhooks(cfg.handlerDeleteWebhookEndpoint)))
+	handle("GET /admin/webhooks/endpoints/{endpointID}/deliveries", http.HandlerFunc(cfg.appWebhooks(cfg.handlerListWebhookDeliveries)))
+	handle("GET /admin/webhooks/endpoints/{endpointID}/deliveries/{deliveryID}", http.HandlerFunc(cfg.appWebhooks(cfg.handlerGetWebhookDelivery)))
+	handle("POST /admin/webhooks/endpoints/{endpointID}/deliveries/{deliveryID}/retry", http.HandlerFunc(cfg.appWebhooks(cfg.handlerRetryWebhookDelivery)))
+	handle("POST /admin/users/{userID}/suspend", http.HandlerFunc(cfg.handlerSuspendUser))
+	handle("DELETE /admin/users/{userID}/suspend", http.HandlerFunc(cfg.handlerUnsuspendUser))
+	handle("POST /admin/users/{userID}/shadow-ban", http.HandlerFunc(cfg.handlerShadowBanUser))
+	handle("DELETE /admin/users/{userID}/shadow-ban", http.HandlerFunc(cfg.handlerLiftShadowBan))
+	handle("POST /admin/reports/chirps/{chirpID}/resolve", http.HandlerFunc(cfg.handlerResolveReports))
+	handle("POST /api/chirps", cfg.middlewareRateLimit(createChirpPolicy, http.HandlerFunc(cfg.handlerCreateChirp)))
+	handle("GET /api/chirps", http.HandlerFunc(cfg.handlerGetChirps))
+	handle("DELETE /api/chirps/{chirpID}", http.HandlerFunc(cfg.handlerDeleteChirp))
+	handle("GET /api/chirps/{chirpID}", http.HandlerFunc(cfg.handlerGetOneChirp))
+	handle("PUT /api/chirps/{chirpID}", http.HandlerFunc(cfg.handlerEditChirp))
+	handle("GET /api/chirps/{chirpID}/history", http.HandlerFunc(cfg.handlerGetChirpHistory))
+	handle("POST /api/chirps/{chirpID}/restore", http.HandlerFunc(cfg.handlerRestoreChirp))
+	handle("POST /api/chirps/{chirpID}/media", http.HandlerFunc(cfg.handlerUploadAttachment))
+	handle("POST /api/chirps/{chirpID}/reports", http.HandlerFunc(cfg.handlerReportChirp))
+	handle("GET /api/media/{mediaID}", http.HandlerFunc(cfg.handlerGetAttachment))
+	handle("GET /api/media/{mediaID}/thumbnail", http.HandlerFunc(cfg.handlerGetAttachmentThumbnail))
+	handle("GET /api/notifications", http.HandlerFunc(cfg.handlerGetNotifications))
+	handle("POST /api/notifications/{notificationID}/read", http.HandlerFunc(cfg.handlerMarkNotificationRead))
+	handle("POST /api/login", cfg.middlewareRateLimit(loginPolicy, http.HandlerFunc(cfg.handlerUserLogin)))
+	handle("POST /api/refresh", http.HandlerFunc(cfg.handlerRefresh))
+	handle("POST /api/revoke", http.HandlerFunc(cfg.handlerRevokeRefresh))
+	handle("POST /api/polka/webhooks", cfg.middlewareRateLimit(webhookPolicy, http.HandlerFunc(cfg.handlerPolkaWebhook)))
+}
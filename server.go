@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// middlewareLimitBody caps the size of request bodies. Routes that need more
+// room, such as media uploads, are listed in overrides by their mux pattern.
+// Requests that declare a larger body are turned away before they reach the
+// handler; the rest fail on read once they pass the limit.
+func middlewareLimitBody(mux *http.ServeMux, limit int64, overrides map[string]int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		max := limit
+		if _, pattern := mux.Handler(r); pattern != "" {
+			if n, ok := overrides[pattern]; ok {
+				max = n
+			}
+		}
+		if r.ContentLength > max {
+			respondWithError(w, http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge), nil)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// operationalRoutes are the probe and scrape endpoints, by mux pattern.
+var operationalRoutes = map[string]bool{
+	"GET /api/livez":   true,
+	"GET /api/readyz":  true,
+	"GET /api/healthz": true,
+	"GET /metrics":     true,
+}
+
+// middlewareOperational serves operationalRoutes from mux directly and hands
+// everything else to next. Probes and scrapes must not be rate limited or
+// checked against the database for a suspended user: a busy or degraded
+// server would then look dead to the orchestrator watching it.
+func middlewareOperational(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); operationalRoutes[pattern] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// workers runs the background jobs so shutdown can wait for them to stop.
+type workers struct {
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+func newWorkers() *workers {
+	ctx, stop := context.WithCancel(context.Background())
+	return &workers{ctx: ctx, stop: stop}
+}
+
+// Go starts a job. The job should return once its context is cancelled.
+func (ws *workers) Go(job func(ctx context.Context)) {
+	ws.wg.Add(1)
+	go func() {
+		defer ws.wg.Done()
+		job(ws.ctx)
+	}()
+}
+
+// Stop cancels every job and waits for them to return.
+func (ws *workers) Stop() {
+	ws.stop()
+	ws.wg.Wait()
+}
+
+// serve runs the server until ctx is done. It then calls onDrain, which fails
+// readiness, keeps serving for delay so load balancers notice, and finally
+// stops accepting connections and gives in-flight requests up to drain to
+// finish.
+func serve(ctx context.Context, server *http.Server, delay, drain time.Duration, onDrain func()) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	slog.Info("shutting down", "delay", delay, "drain", drain)
+	onDrain()
+	time.Sleep(delay)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/MattInReality/Chirpy/internal/ratelimit"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOperationalRoutesSkipRateLimit(t *testing.T) {
+	ts := newTestServer(t)
+	policy := rateLimitPolicy{
+		name: "test",
+		ip:   ratelimit.Limit{Requests: 1, Window: time.Hour},
+		user: ratelimit.Limit{Requests: 1, Window: time.Hour},
+	}
+	handler := middlewareOperational(ts.mux, ts.cfg.middlewareRateLimit(policy, ts.cfg.middlewareRejectSuspended(ts.mux)))
+	bearer := "Bearer " + token(t, uuid.New())
+
+	get := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", bearer)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, path := range []string{"/api/livez", "/api/readyz", "/api/healthz", "/metrics"} {
+		for i := 0; i < 3; i++ {
+			if code := get(path); code != http.StatusOK {
+				t.Fatalf("GET %s #%d: expected 200, got %d", path, i+1, code)
+			}
+		}
+	}
+	if err := ts.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything else is still limited.
+	get("/api/docs")
+	if code := get("/api/docs"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the second /api/docs to be limited, got %d", code)
+	}
+}
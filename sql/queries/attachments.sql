@@ -0,0 +1,35 @@
+-- name: CreateChirpAttachment :one
+INSERT INTO chirp_attachments (
+  id, created_at, chirp_id, user_id, content_type, size_bytes,
+  width, height, storage_key, thumbnail_key, thumbnail_content_type
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+) RETURNING *;
+
+-- name: GetChirpAttachmentByID :one
+SELECT chirp_attachments.* FROM chirp_attachments
+INNER JOIN chirps ON chirps.id = chirp_attachments.chirp_id
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirp_attachments.id = $1
+AND chirps.deleted_at IS NULL
+AND chirps.hidden_at IS NULL
+AND (users.shadow_banned_at IS NULL OR chirps.user_id = sqlc.narg(viewer_id))
+AND NOT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE (blocks.blocker_id = sqlc.narg(viewer_id) AND blocks.blocked_id = chirps.user_id)
+  OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg(viewer_id))
+);
+
+-- name: GetAttachmentsForChirps :many
+SELECT * FROM chirp_attachments
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY created_at;
+
+-- name: GetAttachmentsForPurge :many
+SELECT chirp_attachments.* FROM chirp_attachments
+INNER JOIN chirps ON chirps.id = chirp_attachments.chirp_id
+WHERE chirps.deleted_at < sqlc.arg(deleted_before)::timestamp
+AND NOT chirps.deleted_by_moderator;
+
+-- name: CountChirpAttachments :one
+SELECT COUNT(*) FROM chirp_attachments WHERE chirp_id = $1;
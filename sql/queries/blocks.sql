@@ -0,0 +1,12 @@
+-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+-- name: UnblockUser :exec
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE (blocker_id = sqlc.arg(user_a) AND blocked_id = sqlc.arg(user_b))
+  OR (blocker_id = sqlc.arg(user_b) AND blocked_id = sqlc.arg(user_a))
+);
@@ -6,19 +6,106 @@ INSERT INTO chirps (
   ) RETURNING *;
 
 -- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-ORDER BY created_at;
+SELECT chirps.* FROM chirps
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirps.deleted_at IS NULL
+AND chirps.hidden_at IS NULL
+AND (users.shadow_banned_at IS NULL OR chirps.user_id = sqlc.narg(viewer_id))
+AND NOT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE (blocks.blocker_id = sqlc.narg(viewer_id) AND blocks.blocked_id = chirps.user_id)
+  OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg(viewer_id))
+)
+AND NOT EXISTS (
+  SELECT 1 FROM mutes
+  WHERE mutes.muter_id = sqlc.narg(viewer_id)
+  AND mutes.muted_id = chirps.user_id
+)
+ORDER BY chirps.created_at;
 
 -- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-WHERE id = $1;
+SELECT chirps.* FROM chirps
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1
+AND chirps.deleted_at IS NULL
+AND chirps.hidden_at IS NULL
+AND (users.shadow_banned_at IS NULL OR chirps.user_id = sqlc.narg(viewer_id))
+AND NOT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE (blocks.blocker_id = sqlc.narg(viewer_id) AND blocks.blocked_id = chirps.user_id)
+  OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg(viewer_id))
+);
 
--- name: DeleteChirp :one
-DELETE FROM chirps WHERE id = $1 AND user_id = $2 RETURNING *;
+-- name: SoftDeleteChirp :one
+UPDATE chirps SET
+  deleted_at = $1
+WHERE id = $2
+AND user_id = $3
+AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RestoreChirp :one
+UPDATE chirps SET
+  deleted_at = NULL
+WHERE id = $1
+AND user_id = $2
+AND deleted_at > sqlc.arg(deleted_after)::timestamp
+AND NOT deleted_by_moderator
+RETURNING *;
+
+-- name: PurgeDeletedChirps :many
+DELETE FROM chirps
+WHERE deleted_at < sqlc.arg(deleted_before)::timestamp
+AND NOT deleted_by_moderator
+RETURNING id;
 
 -- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-WHERE user_id = $1;
+SELECT chirps.* FROM chirps
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1
+AND chirps.deleted_at IS NULL
+AND chirps.hidden_at IS NULL
+AND (users.shadow_banned_at IS NULL OR chirps.user_id = sqlc.narg(viewer_id))
+AND NOT EXISTS (
+  SELECT 1 FROM blocks
+  WHERE (blocks.blocker_id = sqlc.narg(viewer_id) AND blocks.blocked_id = chirps.user_id)
+  OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg(viewer_id))
+)
+AND NOT EXISTS (
+  SELECT 1 FROM mutes
+  WHERE mutes.muter_id = sqlc.narg(viewer_id)
+  AND mutes.muted_id = chirps.user_id
+);
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET
+  body = $1,
+  updated_at = $2,
+  edited_at = $2
+WHERE id = $3
+AND updated_at = $4
+AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetChirpByIDIncludingDeleted :one
+SELECT * FROM chirps WHERE id = $1;
+
+-- name: GetDeletedChirps :many
+SELECT * FROM chirps
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
+-- name: GetChirpsByIDsIncludingDeleted :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: HideChirp :exec
+UPDATE chirps SET
+  hidden_at = $1
+WHERE id = $2;
+
+-- name: ModeratorDeleteChirp :exec
+UPDATE chirps SET
+  deleted_at = COALESCE(deleted_at, $1),
+  deleted_by_moderator = true
+WHERE id = $2;
@@ -0,0 +1,10 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+-- name: UnfollowUser :exec
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+-- name: RemoveFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg(user_a) AND followee_id = sqlc.arg(user_b))
+OR (follower_id = sqlc.arg(user_b) AND followee_id = sqlc.arg(user_a));
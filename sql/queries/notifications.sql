@@ -0,0 +1,16 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, kind, message)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetNotificationsForUser :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 50;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications SET
+  read_at = $1
+WHERE id = $2
+AND user_id = $3;
@@ -0,0 +1,84 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, user_id, url, secret, events, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $6)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1
+AND user_id IS NOT DISTINCT FROM $2;
+
+-- name: GetWebhookEndpointByID :one
+SELECT * FROM webhook_endpoints WHERE id = $1;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id IS NOT DISTINCT FROM $1
+ORDER BY created_at;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1
+AND user_id IS NOT DISTINCT FROM $2;
+
+-- name: ListWebhookEndpointsForEvent :many
+SELECT * FROM webhook_endpoints
+WHERE active
+AND (cardinality(events) = 0 OR sqlc.arg(event)::text = ANY(events))
+AND (user_id IS NULL OR user_id = sqlc.narg(subject_id));
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, endpoint_id, event, payload, next_attempt_at, created_at, traceparent)
+VALUES ($1, $2, $3, $4, $5, $5, $6);
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries SET
+  next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+  SELECT id FROM webhook_deliveries
+  WHERE status = 'pending'
+  AND next_attempt_at <= sqlc.arg(now)
+  ORDER BY next_attempt_at
+  LIMIT sqlc.arg(row_limit)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries SET
+  status = $1,
+  attempts = attempts + 1,
+  next_attempt_at = $2,
+  last_error = $3,
+  delivered_at = $4
+WHERE id = $5;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1
+AND endpoint_id = $2;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+ORDER BY created_at DESC
+LIMIT 100;
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at;
+
+-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries SET
+  status = 'pending',
+  attempts = 0,
+  next_attempt_at = $1
+WHERE id = $2
+AND endpoint_id = $3
+RETURNING *;
@@ -0,0 +1,16 @@
+-- name: EnsureRateLimitBucket :exec
+INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (key) DO NOTHING;
+-- name: GetRateLimitBucketForUpdate :one
+SELECT * FROM rate_limit_buckets
+WHERE key = $1
+FOR UPDATE;
+-- name: UpdateRateLimitBucket :exec
+UPDATE rate_limit_buckets SET
+  tokens = $1,
+  updated_at = $2
+WHERE key = $3;
+-- name: DeleteStaleRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at < $1;
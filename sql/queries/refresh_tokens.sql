@@ -19,3 +19,9 @@ UPDATE refresh_tokens SET
   revoked_at = $1,
   updated_at = $2
 WHERE token = $3;
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens SET
+  revoked_at = $1,
+  updated_at = $2
+WHERE user_id = $3
+AND revoked_at IS NULL;
@@ -0,0 +1,23 @@
+-- name: CreateChirpReport :one
+INSERT INTO chirp_reports (
+  id, created_at, updated_at, chirp_id, reporter_id, reason, details
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: GetOpenReports :many
+SELECT * FROM chirp_reports
+WHERE status = 'open'
+ORDER BY created_at;
+
+-- name: ResolveChirpReports :many
+UPDATE chirp_reports SET
+  status = 'resolved',
+  resolution = $1,
+  resolution_note = $2,
+  resolved_by = $3,
+  resolved_at = $4,
+  updated_at = $4
+WHERE chirp_id = $5
+AND status = 'open'
+RETURNING *;
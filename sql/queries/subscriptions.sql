@@ -0,0 +1,71 @@
+-- name: StartSubscription :one
+INSERT INTO subscriptions (
+  id, user_id, plan, status, current_period_start, current_period_end, created_at, updated_at
+) VALUES (
+  $1, $2, $3, 'active', $4, $5, $6, $6
+)
+ON CONFLICT (user_id) DO UPDATE SET
+  plan = EXCLUDED.plan,
+  status = 'active',
+  current_period_start = EXCLUDED.current_period_start,
+  current_period_end = EXCLUDED.current_period_end,
+  cancel_at_period_end = false,
+  canceled_at = NULL,
+  updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetSubscriptionByUserID :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: RenewSubscription :one
+UPDATE subscriptions SET
+  status = 'active',
+  current_period_start = $1,
+  current_period_end = $2,
+  cancel_at_period_end = false,
+  canceled_at = NULL,
+  updated_at = $3
+WHERE user_id = $4
+RETURNING *;
+
+-- name: CancelSubscriptionAtPeriodEnd :one
+UPDATE subscriptions SET
+  cancel_at_period_end = true,
+  canceled_at = $1,
+  updated_at = $1
+WHERE user_id = $2
+RETURNING *;
+
+-- name: EndSubscription :one
+UPDATE subscriptions SET
+  status = 'canceled',
+  current_period_end = $1,
+  canceled_at = $1,
+  updated_at = $1
+WHERE user_id = $2
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions SET
+  status = 'past_due',
+  updated_at = $1
+WHERE user_id = $2
+RETURNING *;
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions SET
+  status = 'expired',
+  updated_at = sqlc.arg(now)::timestamp
+WHERE status IN ('active', 'past_due')
+AND current_period_end <= sqlc.arg(now)::timestamp
+RETURNING user_id;
+
+-- name: SyncChirpyRed :exec
+UPDATE users SET
+  is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    AND subscriptions.status IN ('active', 'past_due')
+    AND subscriptions.current_period_end > sqlc.arg(now)::timestamp
+  )
+WHERE users.id = sqlc.arg(user_id);
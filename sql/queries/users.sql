@@ -17,7 +17,44 @@ UPDATE users SET
   updated_at = $3
 WHERE id = $4
 RETURNING *;
--- name: UpgradeToRedByID :exec
+-- name: UpdateUserProfile :one
 UPDATE users SET
-  is_chirpy_red = true
+  handle = $1,
+  display_name = $2,
+  bio = $3,
+  location = $4,
+  website = $5,
+  avatar_url = $6,
+  updated_at = $7
+WHERE id = $8
+RETURNING *;
+-- name: GetPublicProfileByID :one
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio,
+  users.location, users.website, users.avatar_url, users.is_chirpy_red,
+  (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL) AS chirp_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE users.id = $1;
+-- name: GetPublicProfileByHandle :one
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio,
+  users.location, users.website, users.avatar_url, users.is_chirpy_red,
+  (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL) AS chirp_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE users.handle = sqlc.arg(handle)::text;
+-- name: SuspendUser :exec
+UPDATE users SET
+  suspended_at = $1,
+  suspended_until = $2
+WHERE id = $3;
+-- name: UnsuspendUser :exec
+UPDATE users SET
+  suspended_at = NULL,
+  suspended_until = NULL
 WHERE id = $1;
+-- name: SetUserShadowBan :exec
+UPDATE users SET
+  shadow_banned_at = $1
+WHERE id = $2;
@@ -0,0 +1,30 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, delivery_id, source, event, payload, received_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (delivery_id) DO NOTHING
+RETURNING *;
+-- name: ClaimWebhookEvent :one
+UPDATE webhook_events SET status = 'processing', claimed_at = sqlc.arg(claimed_at)::timestamp
+WHERE delivery_id = sqlc.arg(delivery_id)
+AND (
+  status IN ('received', 'failed')
+  OR (status = 'processing' AND claimed_at < sqlc.arg(stale_before)::timestamp)
+)
+RETURNING *;
+-- name: GetWebhookEventByDeliveryID :one
+SELECT * FROM webhook_events WHERE delivery_id = $1;
+-- name: GetWebhookEventByID :one
+SELECT * FROM webhook_events WHERE id = $1;
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+ORDER BY received_at DESC
+LIMIT 100;
+-- name: UpdateWebhookEventStatus :one
+UPDATE webhook_events SET
+  status = $1,
+  error = $2,
+  processed_at = $3,
+  attempts = attempts + 1
+WHERE id = $4
+RETURNING *;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN suspended_until TIMESTAMP,
+ADD COLUMN shadow_banned_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN shadow_banned_at,
+DROP COLUMN suspended_until;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+  id UUID PRIMARY KEY,
+  delivery_id TEXT NOT NULL UNIQUE,
+  source TEXT NOT NULL,
+  event TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  status TEXT NOT NULL DEFAULT 'received',
+  error TEXT NOT NULL DEFAULT '',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  received_at TIMESTAMP NOT NULL,
+  processed_at TIMESTAMP
+);
+
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
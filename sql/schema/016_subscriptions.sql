@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL UNIQUE,
+  plan TEXT NOT NULL,
+  status TEXT NOT NULL,
+  current_period_start TIMESTAMP NOT NULL,
+  current_period_end TIMESTAMP NOT NULL,
+  cancel_at_period_end BOOL NOT NULL DEFAULT false,
+  canceled_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX subscriptions_current_period_end_idx ON subscriptions (current_period_end)
+WHERE status IN ('active', 'past_due');
+
+-- Users upgraded before subscriptions existed keep Chirpy Red indefinitely.
+INSERT INTO subscriptions (
+  id, user_id, plan, status, current_period_start, current_period_end, created_at, updated_at
+)
+SELECT gen_random_uuid(), id, 'legacy', 'active', updated_at, '9999-12-31', NOW(), NOW()
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
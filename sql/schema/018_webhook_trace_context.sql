@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE webhook_deliveries ADD COLUMN traceparent TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE webhook_deliveries DROP COLUMN traceparent;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN deleted_by_moderator BOOL NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN deleted_by_moderator;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE webhook_events
+ADD COLUMN claimed_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE webhook_events
+DROP COLUMN claimed_at;
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRedPlan      = "red_monthly"
+	subscriptionPeriod  = 30 * 24 * time.Hour
+	subscriptionExpired = "expired"
+)
+
+// Polka events that change a subscription.
+const (
+	polkaUserUpgraded          = "user.upgraded"
+	polkaUserDowngraded        = "user.downgraded"
+	polkaSubscriptionRenewed   = "subscription.renewed"
+	polkaSubscriptionCancelled = "subscription.canceled"
+	polkaPaymentFailed         = "payment.failed"
+)
+
+var errNoSubscription = errors.New("user has no subscription")
+
+// applySubscriptionEvent updates the user's subscription for a Polka event and
+// then recomputes users.is_chirpy_red from it. is_chirpy_red is never set
+// directly; it only ever reflects the subscription.
+func (cfg *apiConfig) applySubscriptionEvent(ctx context.Context, p polkaEvent) error {
+	now := time.Now()
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if _, err := qtx.GetUserByID(ctx, p.Data.UserID); err != nil {
+		return err
+	}
+
+	switch p.Event {
+	case polkaUserUpgraded:
+		_, err = qtx.StartSubscription(ctx, database.StartSubscriptionParams{
+			ID:                 uuid.New(),
+			UserID:             p.Data.UserID,
+			Plan:               planOf(p),
+			CurrentPeriodStart: now,
+			CurrentPeriodEnd:   periodEnd(p, now),
+			CreatedAt:          now,
+		})
+	case polkaSubscriptionRenewed:
+		var sub database.Subscription
+		sub, err = qtx.GetSubscriptionByUserID(ctx, p.Data.UserID)
+		if err != nil {
+			break
+		}
+		end, ok := renewedPeriodEnd(p, sub, now)
+		if !ok {
+			break
+		}
+		// A renewal that arrives before the period ends extends it; one
+		// that arrives after a lapse starts a new period from now.
+		start := now
+		if sub.CurrentPeriodEnd.After(now) {
+			start = sub.CurrentPeriodEnd
+		}
+		_, err = qtx.RenewSubscription(ctx, database.RenewSubscriptionParams{
+			CurrentPeriodStart: start,
+			CurrentPeriodEnd:   end,
+			UpdatedAt:          now,
+			UserID:             p.Data.UserID,
+		})
+	case polkaSubscriptionCancelled:
+		_, err = qtx.CancelSubscriptionAtPeriodEnd(ctx, database.CancelSubscriptionAtPeriodEndParams{
+			CanceledAt: sql.NullTime{Time: now, Valid: true},
+			UserID:     p.Data.UserID,
+		})
+	case polkaUserDowngraded:
+		_, err = qtx.EndSubscription(ctx, database.EndSubscriptionParams{
+			CurrentPeriodEnd: now,
+			UserID:           p.Data.UserID,
+		})
+	case polkaPaymentFailed:
+		_, err = qtx.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+			UpdatedAt: now,
+			UserID:    p.Data.UserID,
+		})
+	default:
+		return fmt.Errorf("unhandled subscription event %q", p.Event)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNoSubscription
+	}
+	if err != nil {
+		return err
+	}
+	err = qtx.SyncChirpyRed(ctx, database.SyncChirpyRedParams{Now: now, UserID: p.Data.UserID})
+	if err != nil {
+		return err
+	}
+	if p.Event == polkaUserUpgraded {
+		err = publish(ctx, qtx, eventUserUpgraded, p.Data.UserID, userUpgraded{UserID: p.Data.UserID, Plan: planOf(p)})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func planOf(p polkaEvent) string {
+	if p.Data.Plan == "" {
+		return defaultRedPlan
+	}
+	return p.Data.Plan
+}
+
+// periodEnd uses the period end Polka sent, or one period after start.
+func periodEnd(p polkaEvent, start time.Time) time.Time {
+	if p.Data.PeriodEnd != nil && p.Data.PeriodEnd.After(start) {
+		return *p.Data.PeriodEnd
+	}
+	return start.Add(subscriptionPeriod)
+}
+
+// renewedPeriodEnd returns the period end a renewal moves the subscription
+// to, or false when the renewal would not move it forward. Polka's period_end
+// is used as sent. Without one, a renewal is only taken while less than half
+// a period is left, which is never true of a period that was just renewed, so
+// a duplicate or replayed renewal adds nothing.
+func renewedPeriodEnd(p polkaEvent, sub database.Subscription, now time.Time) (time.Time, bool) {
+	if p.Data.PeriodEnd != nil {
+		end := *p.Data.PeriodEnd
+		return end, end.After(sub.CurrentPeriodEnd) && end.After(now)
+	}
+	if sub.CurrentPeriodEnd.Sub(now) > subscriptionPeriod/2 {
+		return time.Time{}, false
+	}
+	start := now
+	if sub.CurrentPeriodEnd.After(now) {
+		start = sub.CurrentPeriodEnd
+	}
+	return start.Add(subscriptionPeriod), true
+}
+
+func isSubscriptionEvent(event string) bool {
+	switch event {
+	case polkaUserUpgraded, polkaUserDowngraded, polkaSubscriptionRenewed, polkaSubscriptionCancelled, polkaPaymentFailed:
+		return true
+	}
+	return false
+}
+
+// runSubscriptionExpiryJob marks subscriptions whose period has ended as
+// expired and takes Chirpy Red away from their users.
+func (cfg *apiConfig) runSubscriptionExpiryJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cfg.expireSubscriptions(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) {
+	now := time.Now()
+	expired, err := cfg.db.ExpireSubscriptions(ctx, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "subscriptions: could not expire subscriptions", "error", err)
+		return
+	}
+	for _, userID := range expired {
+		err := cfg.db.SyncChirpyRed(ctx, database.SyncChirpyRedParams{Now: now, UserID: userID})
+		if err != nil {
+			slog.ErrorContext(ctx, "subscriptions: could not update user", "user_id", userID, "error", err)
+		}
+	}
+	if len(expired) > 0 {
+		slog.InfoContext(ctx, "subscriptions: expired subscriptions", "count", len(expired))
+	}
+}
+
+func (cfg *apiConfig) handlerGetSubscription(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+		return
+	}
+	sub, err := cfg.db.GetSubscriptionByUserID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "no subscription", err)
+		return
+	}
+	type subscription struct {
+		Plan               string     `json:"plan"`
+		Status             string     `json:"status"`
+		CurrentPeriodStart time.Time  `json:"current_period_start"`
+		CurrentPeriodEnd   time.Time  `json:"current_period_end"`
+		CancelAtPeriodEnd  bool       `json:"cancel_at_period_end"`
+		CanceledAt         *time.Time `json:"canceled_at"`
+	}
+	res := subscription{
+		Plan:               sub.Plan,
+		Status:             sub.Status,
+		CurrentPeriodStart: sub.CurrentPeriodStart,
+		CurrentPeriodEnd:   sub.CurrentPeriodEnd,
+		CancelAtPeriodEnd:  sub.CancelAtPeriodEnd,
+	}
+	// The expiry job runs periodically, so report a lapsed period as
+	// expired even if the job has not caught up yet.
+	if !sub.CurrentPeriodEnd.After(time.Now()) && (sub.Status == "active" || sub.Status == "past_due") {
+		res.Status = subscriptionExpired
+	}
+	if sub.CanceledAt.Valid {
+		res.CanceledAt = &sub.CanceledAt.Time
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestRenewalOnlyMovesThePeriodForward(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	user := database.User{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "a@example.com"}
+	subscription := func(end time.Time) database.Subscription {
+		return database.Subscription{
+			ID:                 uuid.New(),
+			UserID:             userID,
+			Plan:               defaultRedPlan,
+			Status:             "active",
+			CurrentPeriodStart: end.Add(-subscriptionPeriod),
+			CurrentPeriodEnd:   end,
+			CreatedAt:          now,
+			UpdatedAt:          now,
+		}
+	}
+	nextEnd := now.Add(2 * time.Hour).Add(subscriptionPeriod)
+
+	testCases := []struct {
+		Name      string
+		PeriodEnd *time.Time
+		Current   time.Time
+		Renews    bool
+	}{
+		{"period_end past the current end", &nextEnd, now.Add(2 * time.Hour), true},
+		{"duplicate with the same period_end", &nextEnd, nextEnd, false},
+		{"no period_end near the end of the period", nil, now.Add(2 * time.Hour), true},
+		{"no period_end on a period just renewed", nil, nextEnd, false},
+	}
+	for _, c := range testCases {
+		t.Run(c.Name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.mock.ExpectBegin()
+			ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(user))
+			ts.mock.ExpectQuery("GetSubscriptionByUserID").WillReturnRows(rows(subscription(c.Current)))
+			if c.Renews {
+				ts.mock.ExpectQuery("RenewSubscription").
+					WithArgs(c.Current, nextEnd, sqlmock.AnyArg(), userID).
+					WillReturnRows(rows(subscription(nextEnd)))
+			}
+			ts.mock.ExpectExec("SyncChirpyRed").WillReturnResult(sqlmock.NewResult(0, 1))
+			ts.mock.ExpectCommit()
+
+			p := polkaEvent{Event: polkaSubscriptionRenewed}
+			p.Data.UserID = userID
+			p.Data.PeriodEnd = c.PeriodEnd
+			if err := ts.cfg.applySubscriptionEvent(context.Background(), p); err != nil {
+				t.Fatal(err)
+			}
+			if err := ts.mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MattInReality/Chirpy/internal/auth"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/MattInReality/Chirpy/internal/problem"
+	"github.com/MattInReality/Chirpy/internal/validate"
+	"github.com/google/uuid"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func init() {
+	validate.Register("duration", `must be a positive Go duration such as "72h"`, func(s string) bool {
+		d, err := time.ParseDuration(s)
+		return err == nil && d > 0
+	})
+}
+
+// isSuspended reports whether u is suspended at now. Time-limited
+// suspensions lift on their own once suspended_until has passed.
+func isSuspended(u database.User, now time.Time) bool {
+	if !u.SuspendedAt.Valid {
+		return false
+	}
+	return !u.SuspendedUntil.Valid || now.Before(u.SuspendedUntil.Time)
+}
+
+func suspendedMessage(u database.User) string {
+	if u.SuspendedUntil.Valid {
+		return fmt.Sprintf("account suspended until %s", u.SuspendedUntil.Time.UTC().Format(time.RFC3339))
+	}
+	return "account suspended"
+}
+
+// viewerID returns the caller's ID when the request carries a valid access
+// token. Endpoints that can be used anonymously use it to personalise results.
+func (cfg *apiConfig) viewerID(r *http.Request) uuid.NullUUID {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+type userLookupKey struct{}
+
+// userLookup holds the caller's user row once it has been loaded, so the rate
+// limiter, the suspension check and the handler share a single query.
+type userLookup struct {
+	mu     sync.Mutex
+	loaded bool
+	id     uuid.UUID
+	user   database.User
+	err    error
+}
+
+// middlewareShareUserLookup gives each request a userLookup for getUser.
+func middlewareShareUserLookup(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userLookupKey{}, &userLookup{})))
+	})
+}
+
+// getUser loads a user by ID. The first user loaded during a request, which
+// is the caller, is remembered for the rest of it. Only use it for reads that
+// do not need to see writes made later in the same request.
+func (cfg *apiConfig) getUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	l, ok := ctx.Value(userLookupKey{}).(*userLookup)
+	if !ok {
+		return cfg.db.GetUserByID(ctx, id)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.loaded && l.id == id {
+		return l.user, l.err
+	}
+	if l.loaded {
+		return cfg.db.GetUserByID(ctx, id)
+	}
+	l.user, l.err = cfg.db.GetUserByID(ctx, id)
+	l.id, l.loaded = id, true
+	return l.user, l.err
+}
+
+// middlewareRejectSuspended turns away any request made with the access token
+// of a suspended user. Requests without a valid access token are passed on
+// untouched so handlers can respond to them as before. If the user cannot be
+// loaded the request is refused with 503 rather than let through unchecked.
+func (cfg *apiConfig) middlewareRejectSuspended(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		viewer := cfg.viewerID(r)
+		if viewer.Valid {
+			user, err := cfg.getUser(r.Context(), viewer.UUID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusServiceUnavailable, "could not check your account, please try again", err)
+				return
+			}
+			if err == nil && isSuspended(user, time.Now()) {
+				respondWithProblem(w, problem.New(http.StatusForbidden, "account_suspended", suspendedMessage(user), nil))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *apiConfig) handlerSuspendUser(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	if userID == moderator.ID {
+		respondWithError(w, http.StatusBadRequest, "you cannot suspend yourself", nil)
+		return
+	}
+	type params struct {
+		Duration string `json:"duration" validate:"duration"`
+	}
+	p := params{}
+	if err := cfg.decodeJSON(w, r, &p); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+	now := time.Now()
+	until := sql.NullTime{}
+	if p.Duration != "" {
+		duration, _ := time.ParseDuration(p.Duration)
+		until = sql.NullTime{Time: now.Add(duration), Valid: true}
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue suspending user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	at := sql.NullTime{Time: now, Valid: true}
+	err = qtx.SuspendUser(r.Context(), database.SuspendUserParams{
+		SuspendedAt:    at,
+		SuspendedUntil: until,
+		ID:             userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue suspending user", err)
+		return
+	}
+	err = qtx.RevokeUserRefreshTokens(r.Context(), database.RevokeUserRefreshTokensParams{
+		RevokedAt: at,
+		UpdatedAt: now,
+		UserID:    userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue suspending user", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue suspending user", err)
+		return
+	}
+	metadata := map[string]any{}
+	if until.Valid {
+		metadata["until"] = until.Time.UTC()
+	}
+	cfg.audit(r, auditEntry{
+		Action:     auditUserSuspend,
+		Actor:      actor(moderator.ID),
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Metadata:   metadata,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	if err := cfg.db.UnsuspendUser(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue lifting suspension", err)
+		return
+	}
+	cfg.audit(r, auditEntry{
+		Action:     auditUserUnsuspend,
+		Actor:      actor(moderator.ID),
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerShadowBanUser(w http.ResponseWriter, r *http.Request) {
+	cfg.setShadowBan(w, r, sql.NullTime{Time: time.Now(), Valid: true})
+}
+
+func (cfg *apiConfig) handlerLiftShadowBan(w http.ResponseWriter, r *http.Request) {
+	cfg.setShadowBan(w, r, sql.NullTime{})
+}
+
+func (cfg *apiConfig) setShadowBan(w http.ResponseWriter, r *http.Request, at sql.NullTime) {
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), err)
+		return
+	}
+	err = cfg.db.SetUserShadowBan(r.Context(), database.SetUserShadowBanParams{
+		ShadowBannedAt: at,
+		ID:             userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "issue updating user", err)
+		return
+	}
+	action := auditUserShadowBan
+	if !at.Valid {
+		action = auditUserShadowBanLift
+	}
+	cfg.audit(r, auditEntry{
+		Action:     action,
+		Actor:      actor(moderator.ID),
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/MattInReality/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRejectSuspended(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	user := database.User{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "a@example.com"}
+	suspended := user
+	suspended.SuspendedAt = sql.NullTime{Time: now, Valid: true}
+
+	get := func(t *testing.T, ts *testServer) *httptest.ResponseRecorder {
+		t.Helper()
+		handler := middlewareShareUserLookup(ts.cfg.middlewareRateLimit(globalPolicy, ts.cfg.middlewareRejectSuspended(ts.mux)))
+		req := httptest.NewRequest("GET", "/api/users/entitlements", nil)
+		req.Header.Set("Authorization", "Bearer "+token(t, userID))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if err := ts.mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	t.Run("the user is loaded once per request", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(user))
+		if rec := get(t, ts); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("suspended", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnRows(rows(suspended))
+		rec := get(t, ts)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), `"code":"account_suspended"`) {
+			t.Fatalf("expected 403 account_suspended, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("the database is down", func(t *testing.T) {
+		ts := newTestServer(t)
+		ts.mock.ExpectQuery("GetUserByID").WillReturnError(errors.New("connection refused"))
+		if rec := get(t, ts); rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
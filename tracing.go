@@ -0,0 +1,39 @@
+package main
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strings"
+)
+
+// middlewareTracing starts a span for every request, continuing the trace in
+// an incoming traceparent header. Spans are named after the mux pattern and
+// carry the route, the request ID and, for signed in users, the user ID.
+func (cfg *apiConfig) middlewareTracing(mux *http.ServeMux, next http.Handler) http.Handler {
+	tagged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		span := trace.SpanFromContext(r.Context())
+		if _, route := mux.Handler(r); route != "" {
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		if id := w.Header().Get(requestIDHeader); id != "" {
+			span.SetAttributes(attribute.String("request.id", id))
+		}
+		if viewer := cfg.viewerID(r); viewer.Valid {
+			span.SetAttributes(attribute.String("user.id", viewer.UUID.String()))
+		}
+	})
+	return otelhttp.NewHandler(tagged, "chirpy", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		_, route := mux.Handler(r)
+		switch {
+		case route == "":
+			return r.Method + " unmatched"
+		case strings.HasPrefix(route, "/"):
+			return r.Method + " " + route
+		default:
+			return route
+		}
+	}))
+}
@@ -1,17 +1,31 @@
 package main
 
 import (
-	"encoding/json"
+	"github.com/MattInReality/Chirpy/internal/config"
+	"github.com/MattInReality/Chirpy/internal/filter"
 	"net/http"
-	"strings"
 )
 
-func getBadWords() map[string]int {
-	return map[string]int{
-		"kerfuffle": 0,
-		"sharbert":  0,
-		"fornax":    0,
+// newProfanityFilter builds the chirp filter from the profanity_words,
+// profanity_file (one word per line) and profanity_mask settings. With
+// neither list set the built in defaults are used.
+func newProfanityFilter(conf *config.Config) (*filter.Filter, error) {
+	mask, err := filter.ParseMask(conf.ProfanityMask)
+	if err != nil {
+		return nil, err
+	}
+	words := append([]string{}, conf.ProfanityWords...)
+	if conf.ProfanityFile != "" {
+		fromFile, err := filter.LoadFile(conf.ProfanityFile)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, fromFile...)
 	}
+	if len(words) == 0 {
+		words = filter.DefaultWords
+	}
+	return filter.New(words, mask), nil
 }
 
 type Chirp struct {
@@ -19,37 +33,17 @@ type Chirp struct {
 	BadWords bool
 }
 
-func sanitisedChirp(chirpText string, badWords map[string]int) string {
-	splitText := strings.Split(chirpText, " ")
-	for i, w := range splitText {
-		l := strings.ToLower(w)
-		if _, ok := badWords[l]; ok {
-			splitText[i] = "****"
-		}
-	}
-	return strings.Join(splitText, " ")
-}
-
-func handlerValidateChirp(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) handlerValidateChirp(w http.ResponseWriter, r *http.Request) {
 	type params struct {
-		Chirp string `json:"body"`
+		Chirp string `json:"body" validate:"required,max=140"`
 	}
-	const maxChirpLength = 140
-	defer r.Body.Close()
-	d := json.NewDecoder(r.Body)
 	p := params{}
 	w.Header().Add("Content-Type", "application/json")
-	err := d.Decode(&p)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
-		return
-	}
-	if len(p.Chirp) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	if err := cfg.decodeJSON(w, r, &p); err != nil {
+		respondWithProblem(w, err)
 		return
 	}
-	badWords := getBadWords()
-	sanitisedChirp := sanitisedChirp(p.Chirp, badWords)
+	sanitisedChirp := cfg.filter.Clean(p.Chirp)
 	type badWordResponse struct {
 		CleanedBody string `json:"cleaned_body"`
 	}